@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tiny-go/codec"
+)
+
+// AsyncStatus is sent by Events handler on every change of the task.
+type AsyncStatus struct {
+	Status   string  `json:"status" xml:"status"`
+	Progress float64 `json:"progress" xml:"progress"`
+	Attempt  int     `json:"attempt" xml:"attempt"`
+}
+
+// Events returns a handler that streams the status of asynchronous task as
+// Server-Sent Events. Task ID should be provided with "Async-Request-ID" header
+// or "id" query parameter (browsers cannot set headers for EventSource). The
+// following events are sent:
+//   - "status" - on every status transition or progress update (see ReportProgress)
+//   - "done" - the result of the task encoded with the data codec
+//   - "error" - the error message if task was completed with an error
+//   - "expired" - the result of the task was not received in time and expired
+//
+// The stream is closed when the task is completed (the result is delivered and
+// deleted in the same way as with AsyncRequest middleware) or expired. The data
+// codec is negotiated separately from the stream, it is looked up in provided
+// registry by the media types of "Accept" header other than "text/event-stream"
+// (e.g. "text/event-stream, application/xml"), the response codec of Codec
+// middleware is used otherwise (unless it is a stream codec) or JSON by default.
+func (ts *AsyncTasks) Events(codecs Codecs) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(asyncRequestID)
+		if requestID == "" {
+			requestID = r.URL.Query().Get("id")
+		}
+		val, ok := ts.jobs.Load(requestID)
+		if !ok {
+			sendError(ts.errorHandler, w, r, "invalid or expired request", http.StatusBadRequest)
+			return
+		}
+		async := val.(*asyncTask)
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			sendError(ts.errorHandler, w, r, ErrStreamingUnsupported.Error(), http.StatusInternalServerError)
+			return
+		}
+		encoder := (&EventStream{Data: eventDataCodec(r, codecs)}).Encoder(w)
+		send := func(name string, data interface{}) error {
+			// nothing is written if the data cannot be encoded
+			err := encoder.Encode(Event{ID: requestID, Name: name, Data: data})
+			flusher.Flush()
+			return err
+		}
+		setEventStreamHeaders(w.Header())
+		w.WriteHeader(http.StatusOK)
+		expired := time.NewTimer(time.Until(async.expires))
+		defer expired.Stop()
+		for {
+			status, progress, changed := async.watch()
+			send("status", AsyncStatus{Status: status.String(), Progress: progress, Attempt: async.Attempts()})
+			if status == StatusDone {
+				if data, err := async.Resolve(); err != nil {
+					send("error", err.Error())
+				} else if err := send("done", data); err != nil {
+					// the client should know that the result is not going to be sent
+					send("error", fmt.Sprintf("cannot encode the result: %s", err))
+				}
+				if async.key == "" {
+					ts.jobs.Delete(async.ID)
+				}
+				return
+			}
+			select {
+			case <-changed:
+			case <-expired.C:
+				send("expired", "invalid or expired request")
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+}
+
+// eventDataCodec negotiates the codec of event data (see Events), nil is returned
+// if there is no suitable codec (JSON is used by EventStream).
+func eventDataCodec(r *http.Request, codecs Codecs) codec.Codec {
+	if codecs != nil {
+		for _, elem := range strings.Split(r.Header.Get(acceptHeader), ",") {
+			mediaType := strings.TrimSpace(strings.SplitN(elem, ";", 2)[0])
+			if mediaType == "" || lookupStreamCodec(mediaType) != nil {
+				continue
+			}
+			if c := codecs.Lookup(mediaType); c != nil && lookupStreamCodec(c.MimeType()) == nil {
+				return c
+			}
+		}
+	}
+	// event data is encoded with the negotiated codec unless it is a stream itself
+	if resCodec := ResponseCodecFromContext(r.Context()); resCodec != nil && lookupStreamCodec(resCodec.MimeType()) == nil {
+		return resCodec
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tiny-go/codec/driver"
+	"github.com/tiny-go/codec/driver/xml"
+)
+
+func handlerProgress(w http.ResponseWriter, r *http.Request) HandlerTask {
+	job, _ := GetHandlerTask(r.Context())
+	if job.Status() == StatusWaiting {
+		var reply []int
+		job.Do(r.Context(), func(stop <-chan struct{}) error {
+			for i := 0; i < 5; i++ {
+				reply = append(reply, i)
+				ReportProgress(r.Context(), float64(i+1)/5)
+				select {
+				case <-stop:
+					return nil
+				case <-time.After(20 * time.Millisecond):
+				}
+			}
+			return job.Complete(reply, nil)
+		})
+	}
+	return job
+}
+
+func Test_AsyncTasks_Events(t *testing.T) {
+	type testCase struct {
+		title  string
+		tasks  *AsyncTasks
+		prefix string
+		suffix string
+	}
+
+	cases := []testCase{
+		{
+			title:  "should stream status transitions and the result of the task",
+			tasks:  NewAsyncTasks(10*time.Millisecond, 300*time.Millisecond, 500*time.Millisecond),
+			prefix: "id: %s\nevent: status\ndata: {\"status\":\"in progress\",",
+			suffix: "id: %s\nevent: status\ndata: {\"status\":\"done\",\"progress\":1,\"attempt\":1}\n\n" +
+				"id: %s\nevent: done\ndata: [0,1,2,3,4]\n\n",
+		},
+		{
+			title:  "should stream an error if the task was not completed in time",
+			tasks:  NewAsyncTasks(10*time.Millisecond, 30*time.Millisecond, 500*time.Millisecond),
+			prefix: "id: %s\nevent: status\ndata: {\"status\":\"in progress\",",
+			suffix: "id: %s\nevent: error\ndata: context deadline exceeded\n\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			handler := tc.tasks.Middleware()(handleResponse(handlerProgress))
+			// start asynchronous task
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(http.MethodGet, "", nil)
+			r.Header.Set(asyncHeader, "")
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("status code %d was expected to be %d", w.Code, http.StatusAccepted)
+			}
+			id := w.Header().Get(asyncRequestID)
+			// subscribe to the task
+			w = httptest.NewRecorder()
+			r, _ = http.NewRequest(http.MethodGet, "/?id="+id, nil)
+			tc.tasks.Events(nil).ServeHTTP(w, r)
+			if ctype := w.Header().Get(contentTypeHeader); ctype != MimeTypeEventStream {
+				t.Errorf("content type %q was expected to be %q", ctype, MimeTypeEventStream)
+			}
+			if buffering := w.Header().Get("X-Accel-Buffering"); buffering != "no" {
+				t.Errorf("X-Accel-Buffering header %q was expected to be %q", buffering, "no")
+			}
+			if prefix := strings.Replace(tc.prefix, "%s", id, -1); !strings.HasPrefix(w.Body.String(), prefix) {
+				t.Errorf("event stream %q was expected to start with %q", w.Body.String(), prefix)
+			}
+			if suffix := strings.Replace(tc.suffix, "%s", id, -1); !strings.HasSuffix(w.Body.String(), suffix) {
+				t.Errorf("event stream %q was expected to end with %q", w.Body.String(), suffix)
+			}
+			// the result should be delivered only once
+			w = httptest.NewRecorder()
+			tc.tasks.Events(nil).ServeHTTP(w, r)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status code %d was expected to be %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func Test_AsyncTasks_Events_dataCodec(t *testing.T) {
+	tasks := NewAsyncTasks(10*time.Millisecond, 300*time.Millisecond, 500*time.Millisecond)
+	handler := tasks.Middleware()(handleResponse(handlerProgress))
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "", nil)
+	r.Header.Set(asyncHeader, "")
+	handler.ServeHTTP(w, r)
+	id := w.Header().Get(asyncRequestID)
+
+	t.Run("should encode the result with the codec of other accepted media type", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "/?id="+id, nil)
+		r.Header.Set(acceptHeader, "text/event-stream, application/xml;q=0.9")
+		tasks.Events(driver.DummyRegistry{&xml.XML{}}).ServeHTTP(w, r)
+		if ctype := w.Header().Get(contentTypeHeader); ctype != MimeTypeEventStream {
+			t.Errorf("content type %q was expected to be %q", ctype, MimeTypeEventStream)
+		}
+		if suffix := "event: done\ndata: <int>0</int><int>1</int><int>2</int><int>3</int><int>4</int>\n\n"; !strings.HasSuffix(w.Body.String(), suffix) {
+			t.Errorf("event stream %q was expected to end with %q", w.Body.String(), suffix)
+		}
+	})
+	t.Run("should send an error if the result cannot be encoded", func(t *testing.T) {
+		tasks := NewAsyncTasks(10*time.Millisecond, 300*time.Millisecond, 500*time.Millisecond)
+		handler := tasks.Middleware()(handleResponse(func(w http.ResponseWriter, r *http.Request) HandlerTask {
+			job, _ := GetHandlerTask(r.Context())
+			if job.Status() == StatusWaiting {
+				job.Do(r.Context(), func(stop <-chan struct{}) error {
+					// the result is not ready in request timeout
+					time.Sleep(30 * time.Millisecond)
+					return job.Complete(map[string]int{"count": 1}, nil)
+				})
+			}
+			return job
+		}))
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "", nil)
+		r.Header.Set(asyncHeader, "")
+		handler.ServeHTTP(w, r)
+		id := w.Header().Get(asyncRequestID)
+
+		w = httptest.NewRecorder()
+		r, _ = http.NewRequest(http.MethodGet, "/?id="+id, nil)
+		r.Header.Set(acceptHeader, "text/event-stream, application/xml")
+		tasks.Events(driver.DummyRegistry{&xml.XML{}}).ServeHTTP(w, r)
+		if suffix := "event: error\ndata: cannot encode the result: xml: unsupported type: map[string]int\n\n"; !strings.HasSuffix(w.Body.String(), suffix) {
+			t.Errorf("event stream %q was expected to end with %q", w.Body.String(), suffix)
+		}
+		if strings.Contains(w.Body.String(), "event: done") {
+			t.Error("done event was not expected")
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+const (
+	// idempotencyKeyHeader allows clients to retry async requests safely.
+	idempotencyKeyHeader = "Idempotency-Key"
+	// defaultMaxIdempotentBody is the default limit of the body of requests with
+	// idempotency key (1 MB).
+	defaultMaxIdempotentBody = 1 << 20
+)
+
+// ErrIdempotencyKeyReused - idempotency key was used with a different request.
+var ErrIdempotencyKeyReused = errors.New("idempotency key has been already used for a different request")
+
+// idempotencyEntry binds idempotency key to the submitted task.
+type idempotencyEntry struct {
+	taskID      string
+	fingerprint string
+}
+
+// WithOwner sets a function that identifies the owner of the request, idempotency
+// keys are scoped per owner (the value of "Authorization" header is used by default).
+// It should be called before the middleware starts serving requests.
+func (ts *AsyncTasks) WithOwner(owner func(*http.Request) string) *AsyncTasks {
+	ts.owner = owner
+	return ts
+}
+
+// WithMaxIdempotentBody sets the maximum size of the body of requests with idempotency
+// key (the body is kept in memory in order to compare the requests), larger requests
+// are rejected with 413 (Request Entity Too Large). It should be called before the
+// middleware starts serving requests.
+func (ts *AsyncTasks) WithMaxIdempotentBody(maxBytes int64) *AsyncTasks {
+	ts.maxIdempotentBody = maxBytes
+	return ts
+}
+
+// lookupKey returns the task submitted with the same idempotency key (should be
+// called under the lock). It fails if the key was used for a different request.
+func (ts *AsyncTasks) lookupKey(scope, fingerprint string) (*asyncTask, error) {
+	val, ok := ts.keys.Load(scope)
+	if !ok {
+		return nil, nil
+	}
+	entry := val.(idempotencyEntry)
+	if entry.fingerprint != fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if task, ok := ts.jobs.Load(entry.taskID); ok {
+		return task.(*asyncTask), nil
+	}
+	// the task has been already deleted - the key can be used again
+	return nil, nil
+}
+
+// defaultOwner identifies the owner of the request by its credentials.
+func defaultOwner(r *http.Request) string {
+	return r.Header.Get(jwtAuthKey)
+}
+
+// idempotencyScope builds unique (per owner) key without keeping credentials in memory.
+func idempotencyScope(owner, key string) string {
+	hash := sha256.Sum256([]byte(owner + "\x00" + key))
+	return hex.EncodeToString(hash[:])
+}
+
+// requestFingerprint calculates the hash of request method, URI and body (the body
+// is restored to be read by the handler). It fails with ErrBodyTooLarge if the
+// body exceeds the limit.
+func requestFingerprint(r *http.Request, maxBytes int64) (string, error) {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	if r.Body != nil {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+		if err != nil {
+			return "", err
+		}
+		if int64(len(body)) > maxBytes {
+			return "", ErrBodyTooLarge
+		}
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		hash.Write(body)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
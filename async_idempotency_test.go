@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_AsyncTasks_idempotency(t *testing.T) {
+	type request struct {
+		title   string
+		timeout time.Duration
+		owner   string
+		body    string
+		sameID  bool
+		code    int
+		data    string
+	}
+
+	requests := []request{
+		{
+			title: "should create a new task",
+			body:  "first",
+			code:  http.StatusAccepted,
+			data:  "request is in progress\n",
+		},
+		{
+			title:  "should return the same task for the request with the same key",
+			body:   "first",
+			sameID: true,
+			code:   http.StatusAccepted,
+			data:   "request is in progress\n",
+		},
+		{
+			title: "should reject the key reused with a different request",
+			body:  "second",
+			code:  http.StatusUnprocessableEntity,
+			data:  ErrIdempotencyKeyReused.Error() + "\n",
+		},
+		{
+			title: "should scope the keys per owner",
+			owner: "another owner",
+			body:  "second",
+			code:  http.StatusAccepted,
+			data:  "request is in progress\n",
+		},
+		{
+			title:   "should return the result of completed task",
+			timeout: 150 * time.Millisecond,
+			body:    "first",
+			code:    http.StatusOK,
+			data:    "[0,1,2,3,4,5,6,7,8,9]\n",
+		},
+		{
+			title: "should keep the result of completed task for the retries",
+			body:  "first",
+			code:  http.StatusOK,
+			data:  "[0,1,2,3,4,5,6,7,8,9]\n",
+		},
+	}
+
+	handler := AsyncRequest(10*time.Millisecond, 300*time.Millisecond, 500*time.Millisecond)(handleResponse(handlerAsync))
+
+	var firstID string
+	for _, req := range requests {
+		t.Run(req.title, func(t *testing.T) {
+			time.Sleep(req.timeout)
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(http.MethodPost, "/jobs", strings.NewReader(req.body))
+			r.Header.Set(asyncHeader, "")
+			r.Header.Set(idempotencyKeyHeader, "key")
+			r.Header.Set(jwtAuthKey, req.owner)
+			handler.ServeHTTP(w, r)
+			if w.Code != req.code {
+				t.Errorf("status code %d is expected to be %d", w.Code, req.code)
+			}
+			if w.Body.String() != req.data {
+				t.Errorf("the output %q is expected to be %q", w.Body.String(), req.data)
+			}
+			id := w.Header().Get(asyncRequestID)
+			if firstID == "" {
+				firstID = id
+			} else if req.sameID && id != firstID {
+				t.Errorf("request ID %q was expected to be %q", id, firstID)
+			} else if !req.sameID && id == firstID {
+				t.Error("request ID was expected to be different")
+			}
+		})
+	}
+
+	t.Run("should reject the body exceeding the limit", func(t *testing.T) {
+		handler := NewAsyncTasks(10*time.Millisecond, 300*time.Millisecond, 500*time.Millisecond).
+			WithMaxIdempotentBody(4).
+			Middleware()(handleResponse(handlerAsync))
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodPost, "/jobs", strings.NewReader("too large"))
+		r.Header.Set(asyncHeader, "")
+		r.Header.Set(idempotencyKeyHeader, "key")
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("status code %d is expected to be %d", w.Code, http.StatusRequestEntityTooLarge)
+		}
+	})
+}
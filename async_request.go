@@ -4,11 +4,14 @@ import (
 	"context"
 	"crypto/md5"
 	"encoding/hex"
-	"errors"
+	stderrors "errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/tiny-go/errors"
 	timap "github.com/tiny-go/timap"
 )
 
@@ -19,6 +22,8 @@ const (
 	StatusInProgress
 	// StatusDone indicates that task was done.
 	StatusDone
+	// StatusScheduled indicates that job execution was delayed by the client.
+	StatusScheduled
 )
 
 // asyncKey is the unique private key which is used (internally) to store/retrieve
@@ -28,20 +33,38 @@ type asyncKey struct{}
 // JobStatus represents the status of asynchronous task.
 type JobStatus int
 
+// String returns human readable job status.
+func (js JobStatus) String() string {
+	switch js {
+	case StatusWaiting:
+		return "waiting"
+	case StatusInProgress:
+		return "in progress"
+	case StatusDone:
+		return "done"
+	case StatusScheduled:
+		return "scheduled"
+	default:
+		return "unknown"
+	}
+}
+
 const (
 	asyncHeader           = "Async-Request"
 	asyncRequestID        = "Async-Request-ID"
 	asyncRequestAccepted  = "Async-Request-Started-At"
 	asyncRequestKeepUntil = "Async-Request-Keep-Until"
+	asyncRequestDelay     = "Async-Request-Delay"
+	asyncRequestAttempt   = "Async-Request-Attempt"
 )
 
 var (
 	// ErrNotCompleted - current job was not completed.
-	ErrNotCompleted = errors.New("task has not been completed")
+	ErrNotCompleted = stderrors.New("task has not been completed")
 	// ErrNotStarted - current job was not started.
-	ErrNotStarted = errors.New("job has not been started")
+	ErrNotStarted = stderrors.New("job has not been started")
 	// ErrAlreadyDone - current job has been already done.
-	ErrAlreadyDone = errors.New("job already completed")
+	ErrAlreadyDone = stderrors.New("job already completed")
 )
 
 // HandlerTask represents sync/async handler task.
@@ -59,18 +82,154 @@ type HandlerTask interface {
 	Resolve() (interface{}, error)
 }
 
+// RetryPolicy describes how the handler is re-invoked if it fails (returns an error
+// or completes the task with an error).
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of handler executions (including the first one).
+	MaxAttempts int
+	// Backoff is a delay before the second attempt, it is doubled for every next one.
+	Backoff time.Duration
+	// MaxBackoff limits the delay between attempts (unlimited if zero).
+	MaxBackoff time.Duration
+	// Retryable reports whether the error is transient (all errors are retried if nil).
+	Retryable func(error) bool
+}
+
+// backoff returns the delay after provided number of attempts.
+func (rp *RetryPolicy) backoff(attempts int) time.Duration {
+	delay := rp.Backoff
+	for i := 1; i < attempts && (rp.MaxBackoff == 0 || delay < rp.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if rp.MaxBackoff > 0 && delay > rp.MaxBackoff {
+		return rp.MaxBackoff
+	}
+	return delay
+}
+
 // base task for sync/async jobs.
 type task struct {
 	sync.Mutex
 	status       JobStatus
+	progress     float64
+	attempts     int
+	retry        *RetryPolicy
 	started      time.Time
 	finished     time.Time
 	asyncTimeout time.Duration
+	// retryable error of current attempt passed to Complete
+	attemptErr error
+	// closed (and replaced) on every change of the task
+	changed chan struct{}
 	// returning params
 	data  interface{}
 	error error
 }
 
+// notify wakes up the watchers of the task (should be called under the lock).
+func (t *task) notify() {
+	if t.changed != nil {
+		close(t.changed)
+	}
+	t.changed = make(chan struct{})
+}
+
+// watch returns current status and progress of the task and the channel which
+// is closed on the next change.
+func (t *task) watch() (JobStatus, float64, <-chan struct{}) {
+	t.Lock()
+	defer t.Unlock()
+	if t.changed == nil {
+		t.changed = make(chan struct{})
+	}
+	return t.status, t.progress, t.changed
+}
+
+// start changes job status to "in progress".
+func (t *task) start() {
+	t.Lock()
+	defer t.Unlock()
+	t.status, t.started = StatusInProgress, time.Now()
+	t.notify()
+}
+
+// startedAt returns the time when job was started (or is going to be started).
+func (t *task) startedAt() time.Time {
+	t.Lock()
+	defer t.Unlock()
+	return t.started
+}
+
+// Attempts returns the number of handler executions.
+func (t *task) Attempts() int {
+	t.Lock()
+	defer t.Unlock()
+	return t.attempts
+}
+
+// canRetry checks if the handler should be called again after failure (should
+// be called under the lock). Timeouts are never retried, the task which has been
+// already done (e.g. expired) is not retried regardless of the error.
+func (t *task) canRetry(err error) bool {
+	return err != nil && err != context.DeadlineExceeded && t.status != StatusDone && t.retry != nil &&
+		t.attempts < t.retry.MaxAttempts && (t.retry.Retryable == nil || t.retry.Retryable(err))
+}
+
+// run calls the handler and retries it according to the retry policy until it
+// succeeds, attempts are exhausted or done channel is closed. Attempt func returns
+// the stop channel of the attempt (passed to the handler) and the func that should
+// be called when the attempt is finished.
+func (t *task) run(done <-chan struct{}, attempt func(n int) (<-chan struct{}, func()), handler func(stop <-chan struct{}) error) error {
+	for {
+		t.Lock()
+		t.attempts++
+		t.attemptErr = nil
+		n := t.attempts
+		t.notify()
+		t.Unlock()
+		stop, finish := attempt(n)
+		err := handler(stop)
+		finish()
+		t.Lock()
+		if err == nil {
+			// retryable error was passed to Complete but not returned by the handler
+			err = t.attemptErr
+		}
+		retry := t.canRetry(err)
+		t.Unlock()
+		if !retry {
+			return err
+		}
+		select {
+		case <-time.After(t.retry.backoff(n)):
+		case <-done:
+			return err
+		}
+	}
+}
+
+// expire completes the task with context.DeadlineExceeded error unless provided
+// attempt has been already finished.
+func (t *task) expire(attempt int) {
+	t.Lock()
+	defer t.Unlock()
+	if t.attempts != attempt || t.status != StatusInProgress {
+		return
+	}
+	t.data, t.error, t.status, t.finished = nil, context.DeadlineExceeded, StatusDone, time.Now()
+	t.notify()
+}
+
+// SetProgress updates the progress of the task which is in progress.
+func (t *task) SetProgress(progress float64) {
+	t.Lock()
+	defer t.Unlock()
+	if t.status == StatusInProgress {
+		t.progress = progress
+		t.notify()
+	}
+}
+
 // Status returns status of the current task.
 func (t *task) Status() JobStatus {
 	t.Lock()
@@ -88,17 +247,25 @@ func (t *task) Resolve() (interface{}, error) {
 	return t.data, t.error
 }
 
-// Complete the task with some result and error, change status to "done".
+// Complete the task with some result and error, change status to "done". If the
+// error can be retried according to the retry policy the task stays in progress
+// and the error is returned (the handler is going to be called again).
 func (t *task) Complete(data interface{}, err error) error {
 	t.Lock()
 	defer t.Unlock()
 	switch t.status {
-	case StatusWaiting:
+	case StatusWaiting, StatusScheduled:
 		return ErrNotStarted
 	case StatusDone:
 		return ErrAlreadyDone
 	default:
+		if t.canRetry(err) {
+			// memorize the error in case if the handler does not return it
+			t.attemptErr = err
+			return err
+		}
 		t.data, t.error, t.status, t.finished = data, err, StatusDone, time.Now()
+		t.notify()
 		return err
 	}
 }
@@ -109,21 +276,23 @@ type syncTask struct {
 }
 
 // newAsyncTask is a constructor func for synchronous job.
-func newSyncTask(reqTimeout time.Duration) *syncTask {
-	return &syncTask{task: &task{}}
+func newSyncTask(reqTimeout time.Duration, retry *RetryPolicy) *syncTask {
+	return &syncTask{task: &task{retry: retry}}
 }
 
 // Do executes handler (handler should be a closure - otherwise you will not be
 // able to pass arguments to it or call Complete() in order to return some value).
 func (st *syncTask) Do(ctx context.Context, handler func(stop <-chan struct{}) error) {
 	// memorize start time and change job status
-	st.Lock()
-	st.status, st.started = StatusInProgress, time.Now()
-	st.Unlock()
+	st.start()
 	// error chan
 	errChan := make(chan error, 1)
 	// call handler in goroutine
-	go func() { errChan <- handler(ctx.Done()) }()
+	go func() {
+		errChan <- st.run(ctx.Done(), func(int) (<-chan struct{}, func()) {
+			return ctx.Done(), func() {}
+		}, handler)
+	}()
 	// wait until context deadline or job is done
 	select {
 	// job was done
@@ -141,43 +310,71 @@ type asyncTask struct {
 	*task
 	// unique request ID
 	ID string
+	// the result is deleted after this time
+	expires time.Time
+	// execution is postponed by the client
+	delay time.Duration
+	// idempotency key scope (if task was submitted with the key)
+	key string
 }
 
 // newAsyncTask is a constructor func for asynchronous job.
-func newAsyncTask(execTimeout time.Duration) *asyncTask {
+func newAsyncTask(execTimeout time.Duration, retry *RetryPolicy) *asyncTask {
 	id := md5.Sum([]byte(time.Now().String()))
 	return &asyncTask{
 		ID: hex.EncodeToString(id[:]),
 		task: &task{
 			asyncTimeout: execTimeout,
+			retry:        retry,
 		},
 	}
 }
 
+// begin changes job status to "in progress" (or "scheduled" if execution was
+// delayed). It returns false if the job has been already started (for instance
+// by concurrent request with the same idempotency key).
+func (at *asyncTask) begin() bool {
+	at.Lock()
+	defer at.Unlock()
+	if at.status != StatusWaiting {
+		return false
+	}
+	if at.delay > 0 {
+		at.status, at.started = StatusScheduled, time.Now().Add(at.delay)
+	} else {
+		at.status, at.started = StatusInProgress, time.Now()
+	}
+	at.notify()
+	return true
+}
+
 // Do handles asynchronous execution of the handler.
 func (at *asyncTask) Do(ctx context.Context, handler func(stop <-chan struct{}) error) {
 	// memorize start time and change job status
-	at.Lock()
-	at.status, at.started = StatusInProgress, time.Now()
-	at.Unlock()
+	if !at.begin() {
+		return
+	}
 	// error chan
 	errChan := make(chan error, 1)
 	// call handler in goroutine
 	go func() {
-		// call the handler with actual (execution) timeout channel
-		errChan <- handler(func() <-chan struct{} {
+		if at.delay > 0 {
+			// wait for the scheduled time
+			<-time.After(at.delay)
+			at.start()
+		}
+		// call the handler with actual (execution) timeout channel of each attempt
+		errChan <- at.run(nil, func(n int) (<-chan struct{}, func()) {
 			// context deadline channel
-			ch := make(chan struct{}, 1)
-			// run timer in a new goroutine
-			go func() {
-				<-time.NewTimer(at.asyncTimeout).C
-				// channel may be closed after job is done (in some time)
+			ch := make(chan struct{})
+			timer := time.AfterFunc(at.asyncTimeout, func() {
 				close(ch)
-				// complete the task with context deadline error
-				at.Complete(nil, context.DeadlineExceeded)
-			}()
-			return ch
-		}())
+				// complete the task with context deadline error (unless the timer
+				// belongs to the previous attempt)
+				at.expire(n)
+			})
+			return ch, func() { timer.Stop() }
+		}, handler)
 	}()
 	// wait until context deadline or job is done
 	select {
@@ -201,8 +398,8 @@ func (at *asyncTask) Do(ctx context.Context, handler func(stop <-chan struct{})
 // reqTimeout - time allotted for processing HTTP request, if request has not been
 // processed completely - returns an ID of request (to retrieve result later).
 //
-// asyncTimeout - maximum time for async job to be done (actual context deadline),
-// this logic should be implemented in asynchronous handler or skipped - in that case
+// asyncTimeout - maximum time for async job to be done (actual context deadline
+// of each attempt if the handler is retried, see WithRetry), this logic should be implemented in asynchronous handler or skipped - in that case
 // handler cannot be interrupted.
 //
 // keepResult - at the expiration of a given period of time the result will be
@@ -211,16 +408,83 @@ func (at *asyncTask) Do(ctx context.Context, handler func(stop <-chan struct{})
 // NOTE: Do not use defer statements to check the status of task, send error or
 // any response when using PanicRecover middleware.
 func AsyncRequest(reqTimeout, asyncTimeout, keepResult time.Duration) Middleware {
+	return NewAsyncTasks(reqTimeout, asyncTimeout, keepResult).Middleware()
+}
+
+// AsyncTasks is a list of asynchronous tasks, it provides AsyncRequest middleware
+// and Events handler sharing the same tasks.
+type AsyncTasks struct {
+	reqTimeout   time.Duration
+	asyncTimeout time.Duration
+	keepResult   time.Duration
+	retry        *RetryPolicy
+	maxDelay     time.Duration
+	// maximum size of the body of requests with idempotency key
+	maxIdempotentBody int64
+	jobs              timap.Timap
+	// idempotency keys of submitted tasks
+	keys  timap.Timap
+	owner func(*http.Request) string
+	// sends the errors of the middleware and Events handler
+	errorHandler errors.HandlerFunc
+	mu           sync.Mutex
+}
+
+// NewAsyncTasks is a constructor func for the list of asynchronous tasks (see
+// AsyncRequest for the description of parameters). Asynchronous requests can be
+// delayed by the client with "Async-Request-Delay" header (number of seconds or
+// duration string, it cannot exceed keepResult by default, see WithMaxDelay),
+// the number of handler executions is provided with "Async-Request-Attempt"
+// header while the task is in progress.
+func NewAsyncTasks(reqTimeout, asyncTimeout, keepResult time.Duration) *AsyncTasks {
 	// no sense to use this middleware if the following condition is not satisfied
 	if !(reqTimeout < asyncTimeout && asyncTimeout < keepResult) {
 		panic("request timeout should be less than async timeout and keep result should be greater than async timeout")
 	}
-	// each handler has its own task list
-	var asyncJobs = timap.New(keepResult)
+	return &AsyncTasks{
+		reqTimeout:        reqTimeout,
+		asyncTimeout:      asyncTimeout,
+		keepResult:        keepResult,
+		maxDelay:          keepResult,
+		maxIdempotentBody: defaultMaxIdempotentBody,
+		jobs:              timap.New(keepResult),
+		keys:              timap.New(keepResult),
+		owner:             defaultOwner,
+		errorHandler:      http.Error,
+	}
+}
+
+// WithRetry sets the retry policy for the handlers of the tasks (it should be
+// called before the middleware starts serving requests).
+func (ts *AsyncTasks) WithRetry(policy RetryPolicy) *AsyncTasks {
+	ts.retry = &policy
+	return ts
+}
+
+// WithMaxDelay sets the maximum delay of execution that can be requested by the
+// client with "Async-Request-Delay" header, requests with greater delay are rejected
+// with 400 (Bad Request). It should be called before the middleware starts serving
+// requests.
+func (ts *AsyncTasks) WithMaxDelay(maxDelay time.Duration) *AsyncTasks {
+	ts.maxDelay = maxDelay
+	return ts
+}
+
+// WithErrorHandler sets errors.HandlerFunc which is used to send the errors of
+// the middleware and Events handler (http.Error by default).
+func (ts *AsyncTasks) WithErrorHandler(fn errors.HandlerFunc) *AsyncTasks {
+	if fn != nil {
+		ts.errorHandler = fn
+	}
+	return ts
+}
+
+// Middleware returns AsyncRequest middleware which stores its tasks in the list.
+func (ts *AsyncTasks) Middleware() Middleware {
 	// create a new Middleware
 	return func(next http.Handler) http.Handler {
 		// set timeout with ContextDeadline middleware func
-		return ContextDeadline(reqTimeout)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		return ContextDeadline(ts.reqTimeout)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// check if async request (should contain async header)
 			if _, ok := r.Header[asyncHeader]; ok {
 				// current request
@@ -228,19 +492,25 @@ func AsyncRequest(reqTimeout, asyncTimeout, keepResult time.Duration) Middleware
 				// if contains ID - it is not a new request
 				if requestID := r.Header.Get(asyncRequestID); requestID != "" {
 					// find async job
-					val, ok := asyncJobs.Load(requestID)
+					val, ok := ts.jobs.Load(requestID)
 					if !ok {
 						// async request is expired or has invalid ID
-						http.Error(w, "invalid or expired request", http.StatusBadRequest)
+						sendError(ts.errorHandler, w, r, "invalid or expired request", http.StatusBadRequest)
 						// skip next middleware/handlers
 						return
 					}
 					async = val.(*asyncTask)
 				} else {
-					// create new async task
-					async = newAsyncTask(asyncTimeout)
-					//  and store in the list
-					asyncJobs.Store(async.ID, async)
+					// create new async task (or find the one submitted with the same
+					// idempotency key)
+					var (
+						code int
+						err  error
+					)
+					if async, code, err = ts.submit(r); err != nil {
+						sendError(ts.errorHandler, w, r, err.Error(), code)
+						return
+					}
 				}
 				// get context from request
 				ctx := r.Context()
@@ -252,12 +522,16 @@ func AsyncRequest(reqTimeout, asyncTimeout, keepResult time.Duration) Middleware
 				next.ServeHTTP(w, r)
 				// check the status of async task
 				if async.Status() == StatusDone {
-					asyncJobs.Delete(async.ID)
+					// idempotent tasks are kept until expiration (to reply to retries)
+					if async.key == "" {
+						ts.jobs.Delete(async.ID)
+					}
 				} else {
 					// return request ID
 					w.Header().Set(asyncRequestID, async.ID)
-					w.Header().Set(asyncRequestAccepted, async.started.Format(DefaultTimeFormat))
-					w.Header().Set(asyncRequestKeepUntil, async.started.Add(keepResult).Format(DefaultTimeFormat))
+					w.Header().Set(asyncRequestAccepted, async.startedAt().Format(DefaultTimeFormat))
+					w.Header().Set(asyncRequestKeepUntil, async.startedAt().Add(ts.keepResult).Format(DefaultTimeFormat))
+					w.Header().Set(asyncRequestAttempt, strconv.Itoa(async.Attempts()))
 					// the status ot request is "accepted"
 					w.WriteHeader(http.StatusAccepted)
 					// provide a basic info message to the client
@@ -265,26 +539,94 @@ func AsyncRequest(reqTimeout, asyncTimeout, keepResult time.Duration) Middleware
 				}
 			} else {
 				// create synchronous job
-				sync := newSyncTask(reqTimeout)
+				sync := newSyncTask(ts.reqTimeout, ts.retry)
 				// get context from request
 				ctx := r.Context()
 				// put async task to the context
 				ctx = context.WithValue(ctx, asyncKey{}, sync)
 				// replace request
 				r = r.WithContext(ctx)
-				// call next handler
-				next.ServeHTTP(w, r)
-				// send timeout code on exit if synchronous job was not done
-				if _, err := sync.Resolve(); err == ErrNotCompleted {
-					http.Error(w, context.DeadlineExceeded.Error(), http.StatusRequestTimeout)
+				// call next handler buffering its response, thus only one response
+				// (handler's or timeout) is sent
+				gw, finished := serveGuarded(w, r, next, false)
+				// send timeout code if synchronous job was not done
+				if _, err := sync.Resolve(); !finished || (err == ErrNotCompleted && !gw.written()) {
+					sendError(ts.errorHandler, w, r, context.DeadlineExceeded.Error(), http.StatusRequestTimeout)
+					return
 				}
+				gw.commit()
 			}
 		}))
 	}
 }
 
+// submit creates a new asynchronous task and stores it in the list. If request
+// contains idempotency key previously submitted task is returned instead.
+func (ts *AsyncTasks) submit(r *http.Request) (*asyncTask, int, error) {
+	// execution can be delayed by the client
+	delay, err := parseAsyncDelay(r.Header.Get(asyncRequestDelay), ts.maxDelay)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	var scope, fingerprint string
+	if key := r.Header.Get(idempotencyKeyHeader); key != "" {
+		if fingerprint, err = requestFingerprint(r, ts.maxIdempotentBody); err == ErrBodyTooLarge {
+			return nil, ErrBodyTooLarge.Code(), err
+		} else if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		scope = idempotencyScope(ts.owner(r), key)
+		// the lock prevents concurrent submissions with the same key
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		if async, err := ts.lookupKey(scope, fingerprint); async != nil || err != nil {
+			return async, http.StatusUnprocessableEntity, err
+		}
+	}
+	// create new async task
+	async := newAsyncTask(ts.asyncTimeout, ts.retry)
+	async.delay, async.key = delay, scope
+	async.expires = time.Now().Add(delay + ts.keepResult)
+	//  and store in the list
+	ts.jobs.Store(async.ID, async, delay+ts.keepResult)
+	if scope != "" {
+		ts.keys.Store(scope, idempotencyEntry{taskID: async.ID, fingerprint: fingerprint}, delay+ts.keepResult)
+	}
+	return async, 0, nil
+}
+
+// parseAsyncDelay parses the delay of async request (number of seconds or duration
+// string like "1m30s") which should not exceed the maximum.
+func parseAsyncDelay(value string, maxDelay time.Duration) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	delay, err := time.ParseDuration(value)
+	if seconds, convErr := strconv.Atoi(value); convErr == nil {
+		delay, err = time.Duration(seconds)*time.Second, nil
+	}
+	if err != nil || delay < 0 {
+		return 0, fmt.Errorf("invalid %s header: %q", asyncRequestDelay, value)
+	}
+	if delay > maxDelay {
+		return 0, fmt.Errorf("%s header exceeds maximum delay %s", asyncRequestDelay, maxDelay)
+	}
+	return delay, nil
+}
+
 // GetHandlerTask extracts current job from context.
 func GetHandlerTask(ctx context.Context) (HandlerTask, bool) {
 	async, ok := ctx.Value(asyncKey{}).(HandlerTask)
 	return async, ok
 }
+
+// ReportProgress updates the progress of current job (see Events handler). It
+// returns false if there is no job in the context or it does not support progress
+// reporting.
+func ReportProgress(ctx context.Context, progress float64) bool {
+	job, ok := ctx.Value(asyncKey{}).(interface{ SetProgress(float64) })
+	if ok {
+		job.SetProgress(progress)
+	}
+	return ok
+}
@@ -1,10 +1,12 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -274,3 +276,309 @@ func Test_AsyncRequest(t *testing.T) {
 		})
 	}
 }
+
+func Test_RetryPolicy_backoff(t *testing.T) {
+	policy := &RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	for attempts, expected := range []time.Duration{10, 10, 20, 40, 50, 50} {
+		if delay := policy.backoff(attempts); delay != expected*time.Millisecond {
+			t.Errorf("delay after %d attempt(s) %s was expected to be %s", attempts, delay, expected*time.Millisecond)
+		}
+	}
+}
+
+func Test_AsyncTasks_retry(t *testing.T) {
+	errTransient := errors.New("transient error")
+
+	// failing returns a handler which fails provided number of times before success
+	failing := func(failures int, fail error) func(w http.ResponseWriter, r *http.Request) HandlerTask {
+		var calls int
+		return func(w http.ResponseWriter, r *http.Request) HandlerTask {
+			job, _ := GetHandlerTask(r.Context())
+			if job.Status() == StatusWaiting {
+				job.Do(r.Context(), func(stop <-chan struct{}) error {
+					if calls++; calls <= failures {
+						return job.Complete(nil, fail)
+					}
+					return job.Complete(calls, nil)
+				})
+			}
+			return job
+		}
+	}
+
+	// ignoring returns a handler which does not return the error of Complete
+	ignoring := func(failures int) func(w http.ResponseWriter, r *http.Request) HandlerTask {
+		var calls int
+		return func(w http.ResponseWriter, r *http.Request) HandlerTask {
+			job, _ := GetHandlerTask(r.Context())
+			if job.Status() == StatusWaiting {
+				job.Do(r.Context(), func(stop <-chan struct{}) error {
+					if calls++; calls <= failures {
+						job.Complete(nil, errTransient)
+						return nil
+					}
+					job.Complete(calls, nil)
+					return nil
+				})
+			}
+			return job
+		}
+	}
+
+	type testCase struct {
+		title   string
+		policy  RetryPolicy
+		handler func(w http.ResponseWriter, r *http.Request) HandlerTask
+		code    int
+		data    string
+	}
+
+	cases := []testCase{
+		{
+			title:   "should retry failed handler until success",
+			policy:  RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+			handler: failing(2, errTransient),
+			code:    http.StatusOK,
+			data:    "3\n",
+		},
+		{
+			title:   "should retry if the handler does not return the error of Complete",
+			policy:  RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+			handler: ignoring(2),
+			code:    http.StatusOK,
+			data:    "3\n",
+		},
+		{
+			title:   "should not treat ignored error as success when attempts are exhausted",
+			policy:  RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond},
+			handler: ignoring(2),
+			code:    http.StatusInternalServerError,
+			data:    "transient error\n",
+		},
+		{
+			title:   "should stop retrying when attempts are exhausted",
+			policy:  RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond},
+			handler: failing(2, errTransient),
+			code:    http.StatusInternalServerError,
+			data:    "transient error\n",
+		},
+		{
+			title: "should not retry the errors which are not retryable",
+			policy: RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, Retryable: func(err error) bool {
+				return err == errTransient
+			}},
+			handler: failing(1, errors.New("fatal error")),
+			code:    http.StatusInternalServerError,
+			data:    "fatal error\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			handler := NewAsyncTasks(100*time.Millisecond, 200*time.Millisecond, 300*time.Millisecond).
+				WithRetry(tc.policy).
+				Middleware()(handleResponse(tc.handler))
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(http.MethodGet, "", nil)
+			handler.ServeHTTP(w, r)
+			if w.Code != tc.code {
+				t.Errorf("status code %d is expected to be %d", w.Code, tc.code)
+			}
+			if w.Body.String() != tc.data {
+				t.Errorf("the output %q is expected to be %q", w.Body.String(), tc.data)
+			}
+		})
+	}
+
+	t.Run("should measure async timeout of each attempt separately", func(t *testing.T) {
+		var calls int
+		handler := NewAsyncTasks(10*time.Millisecond, 200*time.Millisecond, time.Second).
+			WithRetry(RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}).
+			Middleware()(handleResponse(func(w http.ResponseWriter, r *http.Request) HandlerTask {
+			job, _ := GetHandlerTask(r.Context())
+			if job.Status() == StatusWaiting {
+				job.Do(r.Context(), func(stop <-chan struct{}) error {
+					select {
+					case <-stop:
+						return context.DeadlineExceeded
+					case <-time.After(150 * time.Millisecond):
+					}
+					if calls++; calls == 1 {
+						return errTransient
+					}
+					return job.Complete(calls, nil)
+				})
+			}
+			return job
+		}))
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "", nil)
+		r.Header.Set(asyncHeader, "")
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("status code %d is expected to be %d", w.Code, http.StatusAccepted)
+		}
+		// the second attempt finishes after async timeout of the first one
+		time.Sleep(400 * time.Millisecond)
+		r.Header.Set(asyncRequestID, w.Header().Get(asyncRequestID))
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || w.Body.String() != "2\n" {
+			t.Errorf("unexpected response: %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("should not retry the task which has expired", func(t *testing.T) {
+		var calls int32
+		handler := NewAsyncTasks(10*time.Millisecond, 50*time.Millisecond, time.Second).
+			WithRetry(RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}).
+			Middleware()(handleResponse(func(w http.ResponseWriter, r *http.Request) HandlerTask {
+			job, _ := GetHandlerTask(r.Context())
+			if job.Status() == StatusWaiting {
+				job.Do(r.Context(), func(stop <-chan struct{}) error {
+					atomic.AddInt32(&calls, 1)
+					<-stop
+					return errTransient
+				})
+			}
+			return job
+		}))
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "", nil)
+		r.Header.Set(asyncHeader, "")
+		handler.ServeHTTP(w, r)
+		time.Sleep(200 * time.Millisecond)
+		if calls := atomic.LoadInt32(&calls); calls != 1 {
+			t.Errorf("the handler was called %d times instead of 1", calls)
+		}
+		r.Header.Set(asyncRequestID, w.Header().Get(asyncRequestID))
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if data := "context deadline exceeded\n"; w.Body.String() != data {
+			t.Errorf("the output %q is expected to be %q", w.Body.String(), data)
+		}
+	})
+}
+
+func Test_AsyncTasks_delay(t *testing.T) {
+	handler := AsyncRequest(10*time.Millisecond, 200*time.Millisecond, 300*time.Millisecond)(handleResponse(handlerAsync))
+
+	t.Run("should reject invalid delay", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "", nil)
+		r.Header.Set(asyncHeader, "")
+		r.Header.Set(asyncRequestDelay, "-1")
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status code %d is expected to be %d", w.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("should reject the delay exceeding the maximum", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "", nil)
+		r.Header.Set(asyncHeader, "")
+		r.Header.Set(asyncRequestDelay, "999999h")
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status code %d is expected to be %d", w.Code, http.StatusBadRequest)
+		}
+		if body := "Async-Request-Delay header exceeds maximum delay 300ms\n"; w.Body.String() != body {
+			t.Errorf("the output %q is expected to be %q", w.Body.String(), body)
+		}
+	})
+
+	t.Run("should postpone execution of the handler", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "", nil)
+		r.Header.Set(asyncHeader, "")
+		r.Header.Set(asyncRequestDelay, "50ms")
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("status code %d is expected to be %d", w.Code, http.StatusAccepted)
+		}
+		if attempt := w.Header().Get(asyncRequestAttempt); attempt != "0" {
+			t.Errorf("attempt %q was expected to be \"0\"", attempt)
+		}
+		started, err := time.Parse(DefaultTimeFormat, w.Header().Get(asyncRequestAccepted))
+		if err != nil || started.Before(time.Now().Truncate(time.Second)) {
+			t.Errorf("start time %q should be in the future", w.Header().Get(asyncRequestAccepted))
+		}
+		// wait until the job is done
+		time.Sleep(200 * time.Millisecond)
+		r.Header.Set(asyncRequestID, w.Header().Get(asyncRequestID))
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("status code %d is expected to be %d", w.Code, http.StatusOK)
+		}
+		if data := "[0,1,2,3,4,5,6,7,8,9]\n"; w.Body.String() != data {
+			t.Errorf("the output %q is expected to be %q", w.Body.String(), data)
+		}
+	})
+}
+
+func Test_AsyncRequest_sync_response(t *testing.T) {
+	t.Run("should ignore late writes of the handler after timeout response", func(t *testing.T) {
+		lateErr := make(chan error, 1)
+		handler := AsyncRequest(10*time.Millisecond, 20*time.Millisecond, 30*time.Millisecond)(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				job, _ := GetHandlerTask(r.Context())
+				job.Do(r.Context(), func(stop <-chan struct{}) error {
+					<-stop
+					return nil
+				})
+				time.Sleep(20 * time.Millisecond)
+				w.WriteHeader(http.StatusInternalServerError)
+				_, err := w.Write([]byte("late response"))
+				lateErr <- err
+			}),
+		)
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("", "", nil)
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusRequestTimeout {
+			t.Errorf("status code %d is expected to be %d", w.Code, http.StatusRequestTimeout)
+		}
+		if err := <-lateErr; err != http.ErrHandlerTimeout {
+			t.Errorf("late write error %v is expected to be %v", err, http.ErrHandlerTimeout)
+		}
+		if data := "context deadline exceeded\n"; w.Body.String() != data {
+			t.Errorf("the output %q is expected to be %q", w.Body.String(), data)
+		}
+	})
+
+	t.Run("should send the response of the handler which did not complete the task", func(t *testing.T) {
+		handler := AsyncRequest(10*time.Millisecond, 20*time.Millisecond, 30*time.Millisecond)(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Test", "passed")
+				http.Error(w, "invalid request", http.StatusBadRequest)
+			}),
+		)
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("", "", nil)
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status code %d is expected to be %d", w.Code, http.StatusBadRequest)
+		}
+		if w.Header().Get("X-Test") != "passed" {
+			t.Error("response headers of the handler were not sent")
+		}
+		if data := "invalid request\n"; w.Body.String() != data {
+			t.Errorf("the output %q is expected to be %q", w.Body.String(), data)
+		}
+	})
+
+	t.Run("should propagate the panic of the handler", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r != "handler panic" {
+				t.Errorf("recovered value %v is expected to be %q", r, "handler panic")
+			}
+		}()
+		handler := AsyncRequest(10*time.Millisecond, 20*time.Millisecond, 30*time.Millisecond)(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("handler panic") }),
+		)
+		r, _ := http.NewRequest("", "", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	})
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	stderrors "errors"
+	"io"
+	"net/http"
+
+	"github.com/tiny-go/errors"
+)
+
+// ErrBodyTooLarge is returned by request body reader when the limit was exceeded,
+// it has HTTP status code 413 (Request Entity Too Large) thus can be sent with
+// errors.Send func.
+var ErrBodyTooLarge = errors.NewStatusError(http.StatusRequestEntityTooLarge, stderrors.New("request body too large"))
+
+// BodyLimit is a middleware that limits the size of request body. Requests with
+// Content-Length exceeding the limit are rejected with 413 status immediately,
+// otherwise the body is wrapped with http.MaxBytesReader and reading beyond the
+// limit fails with ErrBodyTooLarge. Errors are sent with provided errors.HandlerFunc
+// (http.Error by default).
+func BodyLimit(fn errors.HandlerFunc, maxBytes int64) Middleware {
+	if fn == nil {
+		fn = http.Error
+	}
+	return func(next http.Handler) http.Handler {
+		document(next, APIDoc{Responses: []int{http.StatusRequestEntityTooLarge}})
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				sendError(fn, w, r, ErrBodyTooLarge.Error(), ErrBodyTooLarge.Code())
+				return
+			}
+			if r.Body != nil && r.Body != http.NoBody {
+				raw := &countingBody{ReadCloser: r.Body}
+				r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, raw, maxBytes), raw: raw, limit: maxBytes}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitedBody replaces the error of http.MaxBytesReader with ErrBodyTooLarge.
+type limitedBody struct {
+	io.ReadCloser
+	raw   *countingBody
+	limit int64
+}
+
+// Read reads from http.MaxBytesReader, its error is replaced only if the limit
+// has been exceeded (the errors of the original body are returned as is).
+func (lb *limitedBody) Read(p []byte) (int, error) {
+	n, err := lb.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && lb.raw.count > lb.limit {
+		return n, ErrBodyTooLarge
+	}
+	return n, err
+}
+
+// countingBody counts the bytes read from the original request body (reading
+// beyond the limit is the only way for http.MaxBytesReader to detect it).
+type countingBody struct {
+	io.ReadCloser
+	count int64
+}
+
+// Read reads from the original body counting the bytes.
+func (cb *countingBody) Read(p []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(p)
+	cb.count += int64(n)
+	return n, err
+}
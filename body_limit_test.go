@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/tiny-go/errors"
+)
+
+func Test_BodyLimit(t *testing.T) {
+	type testCase struct {
+		title  string
+		body   string
+		length int64
+		code   int
+		out    string
+	}
+
+	cases := []testCase{
+		{
+			title:  "should pass the body within the limit",
+			body:   "0123456789",
+			length: 10,
+			code:   http.StatusOK,
+			out:    "0123456789",
+		},
+		{
+			title:  "should reject request with too large Content-Length",
+			body:   "0123456789a",
+			length: 11,
+			code:   http.StatusRequestEntityTooLarge,
+			out:    "request body too large\n",
+		},
+		{
+			title:  "should fail reading the body of unknown length exceeding the limit",
+			body:   "0123456789a",
+			length: -1,
+			code:   http.StatusRequestEntityTooLarge,
+			out:    "request body too large\n",
+		},
+	}
+
+	handler := BodyLimit(nil, 10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			errors.Send(w, err)
+			return
+		}
+		w.Write(data)
+	}))
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodPost, "", strings.NewReader(tc.body))
+			r.ContentLength = tc.length
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tc.code {
+				t.Errorf("status code %d was expected to be %d", w.Code, tc.code)
+			}
+			if w.Body.String() != tc.out {
+				t.Errorf("response body %q was expected to be %q", w.Body.String(), tc.out)
+			}
+		})
+	}
+
+	t.Run("should not replace the error of the body read up to the limit", func(t *testing.T) {
+		// the second read of the body fails with iotest.ErrTimeout
+		r, _ := http.NewRequest(http.MethodPost, "", iotest.TimeoutReader(strings.NewReader("0123456789")))
+		var err error
+		BodyLimit(nil, 10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err = ioutil.ReadAll(r.Body)
+		})).ServeHTTP(httptest.NewRecorder(), r)
+		if err != iotest.ErrTimeout {
+			t.Errorf("error %v was expected to be %v", err, iotest.ErrTimeout)
+		}
+	})
+}
@@ -0,0 +1,412 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tiny-go/errors"
+)
+
+// Spec is a declarative description of the middleware (see Registry.Build).
+type Spec struct {
+	Name   string                 `json:"name"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+// Params provides typed access to the parameters of the middleware. Getters
+// memorize the problems (invalid types, missing required parameters) instead of
+// returning them, the problems (including unknown parameters) are reported by
+// the builder after calling the factory.
+type Params struct {
+	values   map[string]interface{}
+	used     map[string]bool
+	failed   map[string]bool
+	problems []string
+}
+
+func newParams(values map[string]interface{}) *Params {
+	return &Params{values: values, used: make(map[string]bool), failed: make(map[string]bool)}
+}
+
+// problem records the problem of the parameter (only the first one is recorded).
+func (p *Params) problem(key, format string, args ...interface{}) {
+	if p.failed[key] {
+		return
+	}
+	p.failed[key] = true
+	p.problems = append(p.problems, fmt.Sprintf("parameter %q ", key)+fmt.Sprintf(format, args...))
+}
+
+// lookup returns the value of the parameter marking it as used.
+func (p *Params) lookup(key string, required bool) (interface{}, bool) {
+	p.used[key] = true
+	value, ok := p.values[key]
+	if !ok && required {
+		p.problem(key, "is required")
+	}
+	return value, ok
+}
+
+func (p *Params) invalid(key, expected string, value interface{}) {
+	p.problem(key, "should be %s, got %T", expected, value)
+}
+
+// Has reports whether the parameter was provided.
+func (p *Params) Has(key string) bool {
+	_, ok := p.values[key]
+	return ok
+}
+
+// Int returns the value of integer parameter (or default value if parameter is
+// missing and not required).
+func (p *Params) Int(key string, def int64, required bool) int64 {
+	value, ok := p.lookup(key, required)
+	if !ok {
+		return def
+	}
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		// float64 values out of int64 range cannot be converted
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			return int64(v)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+	}
+	p.invalid(key, "an integer", value)
+	return def
+}
+
+// String returns the value of string parameter.
+func (p *Params) String(key string, def string, required bool) string {
+	value, ok := p.lookup(key, required)
+	if !ok {
+		return def
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	p.invalid(key, "a string", value)
+	return def
+}
+
+// Bool returns the value of boolean parameter.
+func (p *Params) Bool(key string, def bool, required bool) bool {
+	value, ok := p.lookup(key, required)
+	if !ok {
+		return def
+	}
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	p.invalid(key, "a boolean", value)
+	return def
+}
+
+// Duration returns the value of duration parameter, it can be provided as a string
+// (see time.ParseDuration) or a number of seconds.
+func (p *Params) Duration(key string, def time.Duration, required bool) time.Duration {
+	value, ok := p.lookup(key, required)
+	if !ok {
+		return def
+	}
+	switch v := value.(type) {
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	case int:
+		if d, ok := secondsDuration(float64(v)); ok {
+			return d
+		}
+	case int64:
+		if d, ok := secondsDuration(float64(v)); ok {
+			return d
+		}
+	case float64:
+		if d, ok := secondsDuration(v); ok {
+			return d
+		}
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			if d, ok := secondsDuration(f); ok {
+				return d
+			}
+		}
+	}
+	p.invalid(key, "a duration", value)
+	return def
+}
+
+// secondsDuration converts the number of seconds to time.Duration, it returns
+// false if the duration is out of range.
+func secondsDuration(seconds float64) (time.Duration, bool) {
+	ns := seconds * float64(time.Second)
+	if ns < math.MinInt64 || ns >= math.MaxInt64 || math.IsNaN(ns) {
+		return 0, false
+	}
+	return time.Duration(ns), true
+}
+
+// StringMap returns the value of parameter containing a map of strings.
+func (p *Params) StringMap(key string, required bool) map[string]string {
+	value, ok := p.lookup(key, required)
+	if !ok {
+		return nil
+	}
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		if m, ok := value.(map[string]string); ok {
+			return m
+		}
+		p.invalid(key, "a map of strings", value)
+		return nil
+	}
+	m := make(map[string]string, len(values))
+	for k, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			p.invalid(key+"."+k, "a string", v)
+			continue
+		}
+		m[k] = s
+	}
+	return m
+}
+
+// Fail reports the problem with the value of parameter (unless the problem has
+// been already reported for the parameter).
+func (p *Params) Fail(key, reason string) {
+	p.problem(key, "%s", reason)
+}
+
+// Positive checks that provided integer parameter is greater than zero.
+func (p *Params) Positive(key string, value int64) {
+	if value <= 0 {
+		p.Fail(key, "should be positive")
+	}
+}
+
+// PositiveDuration checks that provided duration parameter is greater than zero.
+func (p *Params) PositiveDuration(key string, value time.Duration) {
+	if value <= 0 {
+		p.Fail(key, "should be positive")
+	}
+}
+
+// Failed reports whether any problems of the parameters have been recorded, the
+// factory can return nil middleware in this case (if it cannot be created).
+func (p *Params) Failed() bool {
+	return len(p.problems) > 0
+}
+
+// err returns all the problems of the parameters (including unknown ones).
+func (p *Params) err() error {
+	problems := p.problems
+	var unknown []string
+	for key := range p.values {
+		if !p.used[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		problems = append(problems, fmt.Sprintf("unknown parameter %q", key))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(problems, "; "))
+}
+
+// Factory creates the middleware from provided parameters. The problems of the
+// parameters are reported by the builder, so the factory can return nil middleware
+// without an error if it cannot be created (see Params.Failed).
+type Factory func(params *Params) (Middleware, error)
+
+// Registry contains named middleware factories.
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]Factory
+}
+
+// NewRegistry is a constructor func for an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]Factory)}
+}
+
+// Register adds (or replaces) the factory of the middleware.
+func (reg *Registry) Register(name string, factory Factory) *Registry {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.factories[name] = factory
+	return reg
+}
+
+// Names returns sorted list of registered middleware.
+func (reg *Registry) Names() []string {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	names := make([]string, 0, len(reg.factories))
+	for name := range reg.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Build creates the middleware from provided list of specs (in the order of
+// execution), every middleware is named after its spec (see Named). The error
+// lists the problems of all the specs.
+func (reg *Registry) Build(specs ...Spec) (Middleware, error) {
+	var problems []string
+	middlewares := make([]Middleware, 0, len(specs))
+	for i, spec := range specs {
+		reg.mu.RLock()
+		factory, ok := reg.factories[spec.Name]
+		reg.mu.RUnlock()
+		if !ok {
+			problems = append(problems, fmt.Sprintf("middleware %d: unknown middleware %q", i, spec.Name))
+			continue
+		}
+		params := newParams(spec.Params)
+		mw, err := factory(params)
+		if err == nil {
+			err = params.err()
+		}
+		if err == nil && mw == nil {
+			err = fmt.Errorf("factory returned nil middleware")
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("middleware %d (%s): %v", i, spec.Name, err))
+			continue
+		}
+		middlewares = append(middlewares, Named(spec.Name, mw))
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return NewE(middlewares...)
+}
+
+// BuildJSON creates the middleware from JSON encoded list of specs, for instance:
+//
+//	[
+//	    {"name": "RequestID"},
+//	    {"name": "Throttle", "params": {"count": 100, "duration": "1s"}},
+//	    {"name": "SetHeaders", "params": {"headers": {"X-Frame-Options": "DENY"}}}
+//	]
+func (reg *Registry) BuildJSON(data []byte) (Middleware, error) {
+	var specs []Spec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("cannot decode middleware specs: %v", err)
+	}
+	return reg.Build(specs...)
+}
+
+// BuildYAML creates the middleware from YAML encoded list of specs (a subset of
+// YAML is supported: block sequences and mappings, plain and quoted scalars, flow
+// collections in JSON format and comments), JSON is accepted as well, for instance:
+//
+//	---
+//	- name: RequestID
+//	- name: Throttle
+//	  params: {"count": 100, "duration": "1s"}
+//	- name: SetHeaders
+//	  params:
+//	    headers:
+//	      X-Frame-Options: DENY # or "DENY"
+func (reg *Registry) BuildYAML(data []byte) (Middleware, error) {
+	value, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode middleware specs: %v", err)
+	}
+	// decoded document is converted to the specs in the same way as JSON one
+	if data, err = json.Marshal(value); err != nil {
+		return nil, fmt.Errorf("cannot decode middleware specs: %v", err)
+	}
+	return reg.BuildJSON(data)
+}
+
+// DefaultRegistry contains the factories of the middleware of the package, the
+// names of middleware and parameters match the names of constructor funcs and
+// their arguments:
+//   - BodyClose, ContextHandler, PanicRecover (errors.Send), RequestID
+//   - BodyLimit: maxBytes (required)
+//   - Compress: minSize (DefaultCompressMinSize by default)
+//   - ContextDeadline: timeout (required)
+//   - RequestDecompress: maxRatio (required)
+//   - RequestLimiter: maxConcurrentRequests (required)
+//   - SetHeaders: headers (required)
+//   - Throttle: count, duration (required)
+var DefaultRegistry = NewRegistry().
+	Register("BodyClose", func(*Params) (Middleware, error) { return BodyClose, nil }).
+	Register("ContextHandler", func(*Params) (Middleware, error) { return ContextHandler, nil }).
+	Register("PanicRecover", func(*Params) (Middleware, error) { return PanicRecover(errors.Send), nil }).
+	Register("RequestID", func(*Params) (Middleware, error) { return RequestID, nil }).
+	Register("BodyLimit", func(p *Params) (Middleware, error) {
+		maxBytes := p.Int("maxBytes", 0, true)
+		p.Positive("maxBytes", maxBytes)
+		return BodyLimit(nil, maxBytes), nil
+	}).
+	Register("Compress", func(p *Params) (Middleware, error) {
+		return Compress(int(p.Int("minSize", DefaultCompressMinSize, false))), nil
+	}).
+	Register("ContextDeadline", func(p *Params) (Middleware, error) {
+		timeout := p.Duration("timeout", 0, true)
+		p.PositiveDuration("timeout", timeout)
+		return ContextDeadline(timeout), nil
+	}).
+	Register("RequestDecompress", func(p *Params) (Middleware, error) {
+		maxRatio := p.Int("maxRatio", 0, true)
+		p.Positive("maxRatio", maxRatio)
+		return RequestDecompress(nil, maxRatio), nil
+	}).
+	Register("RequestLimiter", func(p *Params) (Middleware, error) {
+		max := p.Int("maxConcurrentRequests", 0, true)
+		p.Positive("maxConcurrentRequests", max)
+		return RequestLimiter(nil, int(max)), nil
+	}).
+	Register("SetHeaders", func(p *Params) (Middleware, error) {
+		return SetHeaders(p.StringMap("headers", true)), nil
+	}).
+	Register("Throttle", func(p *Params) (Middleware, error) {
+		count, duration := p.Int("count", 0, true), p.Duration("duration", 0, true)
+		p.Positive("count", count)
+		p.PositiveDuration("duration", duration)
+		if !p.Failed() && duration/time.Duration(count) <= 0 {
+			// the interval between requests cannot be shorter than a nanosecond
+			p.Fail("count", "should not exceed the duration in nanoseconds")
+		}
+		if p.Failed() {
+			// cannot create the middleware (problems are reported by builder)
+			return nil, nil
+		}
+		return Throttle(count, duration), nil
+	})
+
+// Build creates the middleware with DefaultRegistry.
+func Build(specs ...Spec) (Middleware, error) {
+	return DefaultRegistry.Build(specs...)
+}
+
+// BuildJSON creates the middleware from JSON with DefaultRegistry.
+func BuildJSON(data []byte) (Middleware, error) {
+	return DefaultRegistry.BuildJSON(data)
+}
+
+// BuildYAML creates the middleware from YAML with DefaultRegistry.
+func BuildYAML(data []byte) (Middleware, error) {
+	return DefaultRegistry.BuildYAML(data)
+}
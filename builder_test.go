@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func Test_BuildJSON(t *testing.T) {
+	type testCase struct {
+		title  string
+		config string
+		names  []ChainInfo
+		err    string
+	}
+
+	cases := []testCase{
+		{
+			title: "should build named middleware from the config",
+			config: `[
+				{"name": "RequestID"},
+				{"name": "SetHeaders", "params": {"headers": {"X-Frame-Options": "DENY"}}},
+				{"name": "Throttle", "params": {"count": 1000, "duration": "1s"}},
+				{"name": "ContextDeadline", "params": {"timeout": 1.5}},
+				{"name": "RequestLimiter", "params": {"maxConcurrentRequests": 10}}
+			]`,
+			names: []ChainInfo{
+				{Name: "RequestID"}, {Name: "SetHeaders"}, {Name: "Throttle"},
+				{Name: "ContextDeadline"}, {Name: "RequestLimiter"},
+			},
+		},
+		{
+			title:  "should fail if config cannot be decoded",
+			config: `{"name": "RequestID"}`,
+			err:    "cannot decode middleware specs: json: cannot unmarshal object into Go value of type []middleware.Spec",
+		},
+		{
+			title:  "should fail if middleware is unknown",
+			config: `[{"name": "RequestID"}, {"name": "Unknown"}]`,
+			err:    "middleware 1: unknown middleware \"Unknown\"",
+		},
+		{
+			title:  "should fail if parameters are invalid",
+			config: `[{"name": "Throttle", "params": {"count": 1.5, "duration": "forever", "burst": 10}}]`,
+			err: "middleware 0 (Throttle): parameter \"count\" should be an integer, got float64; " +
+				"parameter \"duration\" should be a duration, got string; unknown parameter \"burst\"",
+		},
+		{
+			title:  "should fail if required parameters are missing",
+			config: `[{"name": "BodyLimit", "params": {}}]`,
+			err:    "middleware 0 (BodyLimit): parameter \"maxBytes\" is required",
+		},
+		{
+			title:  "should report the problems of all the middleware",
+			config: `[{"name": "BodyLimit", "params": {}}, {"name": "Unknown"}, {"name": "RequestLimiter", "params": {"maxConcurrentRequests": 0}}]`,
+			err: "middleware 0 (BodyLimit): parameter \"maxBytes\" is required; middleware 1: unknown middleware \"Unknown\"; " +
+				"middleware 2 (RequestLimiter): parameter \"maxConcurrentRequests\" should be positive",
+		},
+		{
+			title:  "should validate the interval of Throttle",
+			config: `[{"name": "Throttle", "params": {"count": 10000000000, "duration": "1s"}}]`,
+			err:    "middleware 0 (Throttle): parameter \"count\" should not exceed the duration in nanoseconds",
+		},
+		{
+			title:  "should reject the numbers out of range",
+			config: `[{"name": "Throttle", "params": {"count": 1e19, "duration": 1e19}}]`,
+			err: "middleware 0 (Throttle): parameter \"count\" should be an integer, got float64; " +
+				"parameter \"duration\" should be a duration, got float64",
+		},
+		{
+			title:  "should validate durations",
+			config: `[{"name": "ContextDeadline", "params": {"timeout": 0}}, {"name": "Throttle", "params": {"count": 10, "duration": "-1s"}}]`,
+			err: "middleware 0 (ContextDeadline): parameter \"timeout\" should be positive; " +
+				"middleware 1 (Throttle): parameter \"duration\" should be positive",
+		},
+		{
+			title:  "should validate the values of parameters",
+			config: `[{"name": "RequestLimiter", "params": {"maxConcurrentRequests": 0}}]`,
+			err:    "middleware 0 (RequestLimiter): parameter \"maxConcurrentRequests\" should be positive",
+		},
+		{
+			title:  "should validate the values of nested parameters",
+			config: `[{"name": "SetHeaders", "params": {"headers": {"X-Retry": 1}}}]`,
+			err:    "middleware 0 (SetHeaders): parameter \"headers.X-Retry\" should be a string, got float64",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			mw, err := BuildJSON([]byte(tc.config))
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Errorf("error %v was expected to be %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if names := Describe(mw); !reflect.DeepEqual(names, tc.names) {
+				t.Errorf("chain %v was expected to be %v", names, tc.names)
+			}
+		})
+	}
+}
+
+func Test_BuildYAML(t *testing.T) {
+	type testCase struct {
+		title  string
+		config string
+		names  []ChainInfo
+		err    string
+	}
+
+	cases := []testCase{
+		{
+			title: "should build named middleware from the config",
+			config: `
+---
+# middleware of the API
+- name: RequestID
+- name: SetHeaders
+  params:
+    headers:
+      X-Frame-Options: DENY # deny framing
+- name: Throttle
+  params: {"count": 1000, "duration": "1s"}
+-
+  name: ContextDeadline
+  params:
+    timeout: 1.5
+`,
+			names: []ChainInfo{{Name: "RequestID"}, {Name: "SetHeaders"}, {Name: "Throttle"}, {Name: "ContextDeadline"}},
+		},
+		{
+			title:  "should accept JSON config",
+			config: "[\n\t{\"name\": \"RequestID\"}\n]",
+			names:  []ChainInfo{{Name: "RequestID"}},
+		},
+		{
+			title:  "should fail if config cannot be decoded",
+			config: "- name: RequestID\n   params: {}",
+			err:    "cannot decode middleware specs: line 2: unexpected indentation",
+		},
+		{
+			title:  "should fail if parameters are invalid",
+			config: "- name: Throttle\n  params:\n    count: 1.5\n    duration: forever",
+			err: "middleware 0 (Throttle): parameter \"count\" should be an integer, got float64; " +
+				"parameter \"duration\" should be a duration, got string",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			mw, err := BuildYAML([]byte(tc.config))
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Errorf("error %v was expected to be %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if names := Describe(mw); !reflect.DeepEqual(names, tc.names) {
+				t.Errorf("chain %v was expected to be %v", names, tc.names)
+			}
+		})
+	}
+
+	t.Run("should decode quoted and plain scalars", func(t *testing.T) {
+		value, err := parseYAML([]byte("headers:\n  X-Name: \"don't # comment\"\n  'X-It''s': it's # comment\n  X-Count: 10\n  X-Flag: true"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := map[string]interface{}{"headers": map[string]interface{}{
+			"X-Name": "don't # comment", "X-It's": "it's", "X-Count": int64(10), "X-Flag": true,
+		}}
+		if !reflect.DeepEqual(value, expected) {
+			t.Errorf("decoded value %v was expected to be %v", value, expected)
+		}
+	})
+}
+
+func Test_Registry(t *testing.T) {
+	registry := NewRegistry().Register("Header", func(p *Params) (Middleware, error) {
+		name, value := p.String("name", "", true), p.String("value", "", false)
+		return SetHeaders(map[string]string{name: value}), nil
+	})
+
+	mw, err := registry.Build(Spec{Name: "Header", Params: map[string]interface{}{"name": "X-Version", "value": "1"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w := httptest.NewRecorder()
+	mw.Then(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(w, nil)
+	if value := w.Header().Get("X-Version"); value != "1" {
+		t.Errorf("header value %q was expected to be %q", value, "1")
+	}
+	if names := registry.Names(); !reflect.DeepEqual(names, []string{"Header"}) {
+		t.Errorf("unexpected list of registered middleware: %v", names)
+	}
+}
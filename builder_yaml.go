@@ -0,0 +1,266 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a significant line of YAML document (without comments).
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// yamlParser parses a subset of YAML which is enough to describe middleware specs.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAML decodes YAML document into the values of the same types as JSON
+// decoder produces (except integers which are decoded as int64).
+func parseYAML(data []byte) (interface{}, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		// JSON document (it can span multiple lines unlike flow collections)
+		var value interface{}
+		err := json.Unmarshal(trimmed, &value)
+		return value, err
+	}
+	p := &yamlParser{}
+	for i, text := range strings.Split(string(data), "\n") {
+		text = strings.TrimRight(stripYAMLComment(strings.TrimRight(text, "\r")), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || (p.lines == nil && trimmed == "---") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs cannot be used for indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{number: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+	value, err := p.parseNode(p.lines[0].indent)
+	if err == nil && p.pos < len(p.lines) {
+		err = fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].number)
+	}
+	return value, err
+}
+
+// parseNode parses a block sequence or mapping starting at current line.
+func (p *yamlParser) parseNode(indent int) (interface{}, error) {
+	line := p.lines[p.pos]
+	if isYAMLItem(line.text) {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitYAMLKey(line.text); !ok {
+		// the document (or nested node) is a single scalar
+		p.pos++
+		return parseYAMLScalar(line)
+	}
+	return p.parseMapping(indent)
+}
+
+// parseSequence parses the items of block sequence with provided indentation.
+func (p *yamlParser) parseSequence(indent int) ([]interface{}, error) {
+	list := []interface{}{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent || (line.indent == indent && !isYAMLItem(line.text)) {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.number)
+		}
+		item := strings.TrimLeft(line.text[1:], " ")
+		if item == "" {
+			p.pos++
+			value, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+			continue
+		}
+		// the item is parsed as if it was started on a separate line
+		p.lines[p.pos] = yamlLine{number: line.number, indent: indent + len(line.text) - len(item), text: item}
+		value, err := p.parseNode(p.lines[p.pos].indent)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+	return list, nil
+}
+
+// parseMapping parses the keys of block mapping with provided indentation.
+func (p *yamlParser) parseMapping(indent int) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.number)
+		}
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: mapping key was expected", line.number)
+		}
+		if _, ok := m[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.number, key)
+		}
+		p.pos++
+		var (
+			value interface{}
+			err   error
+		)
+		if rest == "" {
+			// a sequence can be nested with the same indentation as the key
+			if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLItem(p.lines[p.pos].text) {
+				value, err = p.parseSequence(indent)
+			} else {
+				value, err = p.parseNested(indent)
+			}
+		} else {
+			value, err = parseYAMLScalar(yamlLine{number: line.number, indent: indent, text: rest})
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// parseNested parses the node nested deeper than provided indentation (null if
+// there is no such node).
+func (p *yamlParser) parseNested(indent int) (interface{}, error) {
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return p.parseNode(p.lines[p.pos].indent)
+	}
+	return nil, nil
+}
+
+// isYAMLItem reports whether the line starts an item of block sequence.
+func isYAMLItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits the line of block mapping into the key and the value.
+func splitYAMLKey(text string) (key, value string, ok bool) {
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 || !strings.HasPrefix(text[end+1:], ":") {
+			return "", "", false
+		}
+		unquoted, err := unquoteYAML(text[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+		key, value = unquoted, text[end+2:]
+	} else {
+		if text[0] == '{' || text[0] == '[' {
+			return "", "", false
+		}
+		i := strings.Index(text+" ", ": ")
+		if i < 0 {
+			return "", "", false
+		}
+		key, value = strings.TrimSpace(text[:i]), text[i+1:]
+	}
+	if value != "" && value[0] != ' ' {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(value), true
+}
+
+// parseYAMLScalar parses the value of the line as a scalar or JSON collection.
+func parseYAMLScalar(line yamlLine) (interface{}, error) {
+	text := line.text
+	switch {
+	case text[0] == '{' || text[0] == '[':
+		var value interface{}
+		if err := json.Unmarshal([]byte(text), &value); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line.number, err)
+		}
+		return value, nil
+	case text[0] == '"' || text[0] == '\'':
+		if closingQuote(text) != len(text)-1 {
+			return nil, fmt.Errorf("line %d: invalid quoted string %s", line.number, text)
+		}
+		value, err := unquoteYAML(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line.number, err)
+		}
+		return value, nil
+	case text == "~" || text == "null":
+		return nil, nil
+	case text == "true":
+		return true, nil
+	case text == "false":
+		return false, nil
+	}
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return i, nil
+	}
+	if strings.ContainsAny(text[:1], "+-.0123456789") {
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f, nil
+		}
+	}
+	return text, nil
+}
+
+// closingQuote returns the index of the quote closing the string at the beginning
+// of provided text (-1 if it is not closed).
+func closingQuote(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			// escaped single quote
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// unquoteYAML returns the value of single or double quoted string.
+func unquoteYAML(text string) (string, error) {
+	if text[0] == '\'' {
+		return strings.Replace(text[1:len(text)-1], "''", "'", -1), nil
+	}
+	return strconv.Unquote(text)
+}
+
+// stripYAMLComment removes the comment from the line (if any).
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t:,[{", text[i-1]) >= 0):
+			// quoted string starts a token (apostrophe of plain scalar is ignored)
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return text[:i]
+		}
+	}
+	return text
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// ArgumentError describes invalid argument of the chain.
+type ArgumentError struct {
+	// Position is the index of the argument.
+	Position int
+	// Type is the type of the argument.
+	Type string
+	// Nil is true if argument has supported type but it is nil.
+	Nil bool
+}
+
+func (e ArgumentError) String() string {
+	if e.Nil {
+		return fmt.Sprintf("argument %d (%s) is nil", e.Position, e.Type)
+	}
+	return fmt.Sprintf("argument %d has unsupported type %q", e.Position, e.Type)
+}
+
+// DependencyError describes unsatisfied dependency of the middleware (see Depends).
+type DependencyError struct {
+	// Name is the name of the middleware that declared the dependency.
+	Name string
+	// Requires is the name of the middleware that is missing before it.
+	Requires string
+}
+
+func (e DependencyError) String() string {
+	return fmt.Sprintf("%q requires %q before", e.Name, e.Requires)
+}
+
+// ChainError is returned when the chain cannot be built (see ChainE and NewE).
+type ChainError struct {
+	Arguments    []ArgumentError
+	Dependencies []DependencyError
+}
+
+// Error returns the list of all the problems of the chain (to satisfy error interface).
+func (e *ChainError) Error() string {
+	problems := make([]string, 0, len(e.Arguments)+len(e.Dependencies))
+	for _, arg := range e.Arguments {
+		problems = append(problems, arg.String())
+	}
+	for _, dep := range e.Dependencies {
+		problems = append(problems, dep.String())
+	}
+	return "invalid chain: " + strings.Join(problems, "; ")
+}
+
+// empty reports whether the chain does not have any problems.
+func (e *ChainError) empty() bool {
+	return len(e.Arguments) == 0 && len(e.Dependencies) == 0
+}
+
+// NewE is the same as New but it returns an error if any of the middleware is
+// nil or dependencies of the middleware (see Depends) are not satisfied.
+func NewE(middlewares ...Middleware) (Middleware, error) {
+	chainErr := &ChainError{}
+	for i, mw := range middlewares {
+		if mw == nil {
+			chainErr.Arguments = append(chainErr.Arguments, ArgumentError{Position: i, Type: fmt.Sprintf("%T", mw), Nil: true})
+		}
+	}
+	if !chainErr.empty() {
+		return nil, chainErr
+	}
+	mw := New(middlewares...)
+	if err := Verify(mw); err != nil {
+		return nil, err
+	}
+	return mw, nil
+}
+
+// ChainE is the same as Chain but it returns an error instead of panic if any of
+// the arguments is nil or has unsupported type, it also verifies the dependencies
+// of the middleware (see Depends).
+func ChainE(handlers ...interface{}) (http.Handler, error) {
+	if err := checkChain(handlers); err != nil {
+		return nil, err
+	}
+	// the chain is described with a separate build, so the tracer does not leak
+	// into the handler
+	tracer := newChainTracer()
+	buildChain(handlers, withTracer(http.HandlerFunc(blobHandler), tracer), nil)
+	chainErr := &ChainError{}
+	verifyChain(tracer.chain(), map[string]bool{}, chainErr)
+	if !chainErr.empty() {
+		return nil, chainErr
+	}
+	return buildChain(handlers, http.HandlerFunc(blobHandler), nil), nil
+}
+
+// checkChain validates the types of Chain arguments.
+func checkChain(handlers []interface{}) error {
+	chainErr := &ChainError{}
+	for i, handler := range handlers {
+		switch handler.(type) {
+		case func(http.ResponseWriter, *http.Request, http.Handler), MiddlewareFunc,
+			func(http.Handler) http.Handler, Middleware,
+			func(w http.ResponseWriter, r *http.Request) error, HandlerE,
+			func(w http.ResponseWriter, r *http.Request), http.Handler:
+			if value := reflect.ValueOf(handler); isNil(value) {
+				chainErr.Arguments = append(chainErr.Arguments, ArgumentError{Position: i, Type: value.Type().String(), Nil: true})
+			}
+		default:
+			chainErr.Arguments = append(chainErr.Arguments, ArgumentError{Position: i, Type: fmt.Sprintf("%T", handler)})
+		}
+	}
+	if !chainErr.empty() {
+		return chainErr
+	}
+	return nil
+}
+
+// isNil reports whether the value of nillable kind is nil.
+func isNil(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Func, reflect.Ptr, reflect.Map, reflect.Chan, reflect.Slice, reflect.Interface:
+		return value.IsNil()
+	default:
+		return false
+	}
+}
+
+// Depends gives a name to the middleware (see Named) and declares the names of
+// middleware that should be executed before it, dependencies are checked by Verify,
+// NewE and ChainE.
+//
+// Example:
+//
+//	mw.NewE(
+//	    mw.Named("RequestID", mw.RequestID),
+//	    mw.Depends("Logger", logger, "RequestID"),
+//	)
+func Depends(name string, mw Middleware, requires ...string) Middleware {
+	return traced(ChainInfo{Name: name, Requires: requires}, mw)
+}
+
+// Verify checks whether all the dependencies of named middleware (see Depends)
+// are executed before it, returned error is of type *ChainError. The middleware
+// is built with an empty handler in order to describe it (see Describe).
+func Verify(mw Middleware) error {
+	chainErr := &ChainError{}
+	verifyChain(Describe(mw), map[string]bool{}, chainErr)
+	if !chainErr.empty() {
+		return chainErr
+	}
+	return nil
+}
+
+// verifyChain walks the chain in the order of execution (parent middleware goes
+// before the nested ones) collecting unsatisfied dependencies.
+func verifyChain(chain []ChainInfo, executed map[string]bool, chainErr *ChainError) {
+	for _, info := range chain {
+		for _, required := range info.Requires {
+			if !executed[required] {
+				chainErr.Dependencies = append(chainErr.Dependencies, DependencyError{Name: info.Name, Requires: required})
+			}
+		}
+		executed[info.Name] = true
+		verifyChain(info.Children, executed, chainErr)
+	}
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_ChainE(t *testing.T) {
+	type testCase struct {
+		title string
+		args  []interface{}
+		err   string
+	}
+
+	var (
+		nilMiddleware Middleware
+		nilHandler    http.HandlerFunc
+	)
+
+	cases := []testCase{
+		{
+			title: "should build the chain of supported arguments",
+			args:  []interface{}{middlewareOne, middlewareFuncOne, handlerOne, handlerFinal},
+		},
+		{
+			title: "should list all the invalid arguments",
+			args:  []interface{}{middlewareOne, true, nilMiddleware, nil, nilHandler, handlerFinal},
+			err: "invalid chain: argument 1 has unsupported type \"bool\"; " +
+				"argument 2 (middleware.Middleware) is nil; " +
+				"argument 3 has unsupported type \"<nil>\"; " +
+				"argument 4 (http.HandlerFunc) is nil",
+		},
+		{
+			title: "should verify dependencies of named middleware",
+			args: []interface{}{
+				Depends("logger", middlewareOne, "request id"),
+				Named("request id", middlewareTwo),
+				New(Depends("jwt", middlewareThree, "request id", "codec")),
+				handlerFinal,
+			},
+			err: "invalid chain: \"logger\" requires \"request id\" before; \"jwt\" requires \"codec\" before",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			handler, err := ChainE(tc.args...)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				handler.ServeHTTP(httptest.NewRecorder(), nil)
+				return
+			}
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("error %v was expected to be %q", err, tc.err)
+			}
+			if _, ok := err.(*ChainError); !ok {
+				t.Errorf("error of type %T was expected to be *ChainError", err)
+			}
+			if handler != nil {
+				t.Error("handler was not expected to be built")
+			}
+		})
+	}
+
+	t.Run("Chain should panic on unsupported argument", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r != "unsupported argument type \"int\"" {
+				t.Errorf("unexpected panic: %v", r)
+			}
+		}()
+		Chain(1)
+	})
+}
+
+func Test_NewE(t *testing.T) {
+	type testCase struct {
+		title string
+		mws   []Middleware
+		err   string
+	}
+
+	cases := []testCase{
+		{
+			title: "should build the middleware if dependencies are satisfied",
+			mws: []Middleware{
+				Named("request id", middlewareOne),
+				Named("auth", New(Depends("jwt", middlewareTwo, "request id"), Depends("claims", middlewareThree, "jwt"))),
+			},
+		},
+		{
+			title: "should reject nil middleware",
+			mws:   []Middleware{middlewareOne, nil},
+			err:   "invalid chain: argument 1 (middleware.Middleware) is nil",
+		},
+		{
+			title: "should reject middleware with unsatisfied dependencies",
+			mws:   []Middleware{Depends("claims", middlewareThree, "jwt"), Named("jwt", middlewareTwo)},
+			err:   "invalid chain: \"claims\" requires \"jwt\" before",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			mw, err := NewE(tc.mws...)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				w := httptest.NewRecorder()
+				mw.Then(handlerFinal).ServeHTTP(w, nil)
+				if out := "/mw1 before next/mw2 before next/mw3 before next/final handler"; !strings.HasPrefix(w.Body.String(), out) {
+					t.Errorf("the output %q was expected to start with %q", w.Body.String(), out)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("error %v was expected to be %q", err, tc.err)
+			}
+		})
+	}
+}
@@ -26,6 +26,8 @@ type Codecs interface {
 
 // Codec middleware searches for suitable request/response codecs according to
 // "Content-Type"/"Accept" headers and puts the correct codecs into the context.
+// Streaming response codecs (NDJSON and EventStream) are resolved automatically
+// if provided registry does not contain them, see NewStream.
 func Codec(fn errors.HandlerFunc, codecs Codecs) Middleware {
 	if fn == nil {
 		fn = http.Error
@@ -35,13 +37,13 @@ func Codec(fn errors.HandlerFunc, codecs Codecs) Middleware {
 			var reqCodec, resCodec codec.Codec
 			// get request codec
 			if reqCodec = codecs.Lookup(r.Header.Get(contentTypeHeader)); reqCodec == nil {
-				fn(w, fmt.Sprintf("unsupported request codec: %q", r.Header.Get(contentTypeHeader)), http.StatusBadRequest)
+				sendError(fn, w, r, fmt.Sprintf("unsupported request codec: %q", r.Header.Get(contentTypeHeader)), http.StatusBadRequest)
 				return
 			}
 			r = r.WithContext(context.WithValue(r.Context(), codecKey{"req"}, reqCodec))
 			// get response codec
-			if resCodec = codecs.Lookup(r.Header.Get(acceptHeader)); resCodec == nil {
-				fn(w, fmt.Sprintf("unsupported response codec: %q", r.Header.Get(acceptHeader)), http.StatusBadRequest)
+			if resCodec = lookupResponseCodec(codecs, r.Header.Get(acceptHeader)); resCodec == nil {
+				sendError(fn, w, r, fmt.Sprintf("unsupported response codec: %q", r.Header.Get(acceptHeader)), http.StatusBadRequest)
 				return
 			}
 			r = r.WithContext(context.WithValue(r.Context(), codecKey{"res"}, resCodec))
@@ -51,6 +53,15 @@ func Codec(fn errors.HandlerFunc, codecs Codecs) Middleware {
 	}
 }
 
+// lookupResponseCodec searches for response codec in the registry and falls back
+// to built-in streaming codecs.
+func lookupResponseCodec(codecs Codecs, mimeType string) codec.Codec {
+	if c := codecs.Lookup(mimeType); c != nil {
+		return c
+	}
+	return lookupStreamCodec(mimeType)
+}
+
 // RequestCodecFromContext pulls the Codec from a request context or returns nil.
 func RequestCodecFromContext(ctx context.Context) codec.Codec {
 	codec, _ := ctx.Value(codecKey{"req"}).(codec.Codec)
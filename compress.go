@@ -0,0 +1,351 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	acceptEncodingHeader = "Accept-Encoding"
+	varyHeader           = "Vary"
+)
+
+// DefaultCompressMinSize is a minimal size of the response body (in bytes) to be
+// compressed by Compress middleware (if zero size was provided).
+const DefaultCompressMinSize = 1024
+
+// incompressibleTypes contains prefixes of content types that are already compressed.
+var incompressibleTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"audio/",
+	"video/",
+	"font/woff",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-bzip2",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/pdf",
+	"application/octet-stream",
+}
+
+// CompressionEncoder creates compressing writers for a particular content coding.
+type CompressionEncoder interface {
+	// Encoding should return the name of content coding (for instance "gzip").
+	Encoding() string
+	// Writer should return a writer that compresses the data into provided writer,
+	// compressed data should be flushed on Close.
+	Writer(w io.Writer) io.WriteCloser
+}
+
+// resetWriter is a compressing writer that can be reused.
+type resetWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// pooledEncoder is a CompressionEncoder which reuses its writers.
+type pooledEncoder struct {
+	encoding string
+	pool     *sync.Pool
+}
+
+// Encoding returns content coding name.
+func (pe *pooledEncoder) Encoding() string { return pe.encoding }
+
+// Writer takes a writer from the pool, it is returned back on Close.
+func (pe *pooledEncoder) Writer(w io.Writer) io.WriteCloser {
+	rw := pe.pool.Get().(resetWriter)
+	rw.Reset(w)
+	return &pooledWriter{resetWriter: rw, pool: pe.pool}
+}
+
+// pooledWriter returns its writer to the pool on Close.
+type pooledWriter struct {
+	resetWriter
+	pool *sync.Pool
+}
+
+// Close flushes compressed data and puts the writer back to the pool.
+func (pw *pooledWriter) Close() error {
+	err := pw.resetWriter.Close()
+	// pooled writer should not keep the response writer
+	pw.resetWriter.Reset(nil)
+	pw.pool.Put(pw.resetWriter)
+	return err
+}
+
+// GzipEncoder returns pooled gzip encoder with provided compression level, it
+// panics if the level is invalid.
+func GzipEncoder(level int) CompressionEncoder {
+	if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+		panic(err.Error())
+	}
+	return &pooledEncoder{
+		encoding: "gzip",
+		pool: &sync.Pool{New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(nil, level)
+			return w
+		}},
+	}
+}
+
+// DeflateEncoder returns pooled deflate (zlib format) encoder with provided
+// compression level, it panics if the level is invalid.
+func DeflateEncoder(level int) CompressionEncoder {
+	if _, err := zlib.NewWriterLevel(nil, level); err != nil {
+		panic(err.Error())
+	}
+	return &pooledEncoder{
+		encoding: "deflate",
+		pool: &sync.Pool{New: func() interface{} {
+			w, _ := zlib.NewWriterLevel(nil, level)
+			return w
+		}},
+	}
+}
+
+// Compress is a middleware that compresses response body according to the
+// "Accept-Encoding" request header. Encoders are listed in order of preference
+// (gzip and deflate with default compression level are used if not provided),
+// any other content coding (for instance brotli) can be added by implementing
+// CompressionEncoder interface. Responses smaller than minSize bytes and responses
+// with already compressed content types are sent as is.
+//
+// Example:
+//
+//	mw.Compress(512, mw.GzipEncoder(gzip.BestSpeed), mw.DeflateEncoder(zlib.BestSpeed))
+func Compress(minSize int, encoders ...CompressionEncoder) Middleware {
+	if minSize <= 0 {
+		minSize = DefaultCompressMinSize
+	}
+	if len(encoders) == 0 {
+		encoders = []CompressionEncoder{
+			GzipEncoder(gzip.DefaultCompression),
+			DeflateEncoder(zlib.DefaultCompression),
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// response depends on request header (even if it is not compressed)
+			addVary(w.Header(), acceptEncodingHeader)
+			encoder := negotiateEncoding(r.Header.Get(acceptEncodingHeader), encoders)
+			// partial content of compressed response cannot be provided
+			if encoder == nil || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, encoder: encoder, minSize: minSize}
+			defer cw.close()
+			// only optional interfaces supported by original writer are exposed
+			next.ServeHTTP(wrapWriter(&responseWriter{w: cw, start: time.Now()}, w), r)
+		})
+	}
+}
+
+// addVary adds a value to the "Vary" header if it does not contain it yet.
+func addVary(header http.Header, value string) {
+	for _, vary := range header[varyHeader] {
+		for _, v := range strings.Split(vary, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), value) {
+				return
+			}
+		}
+	}
+	header.Add(varyHeader, value)
+}
+
+// negotiateEncoding picks the encoder with the highest quality value, the order
+// of encoders is used when quality values are equal.
+func negotiateEncoding(accept string, encoders []CompressionEncoder) CompressionEncoder {
+	if accept == "" {
+		return nil
+	}
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name == "" {
+			continue
+		}
+		weight := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					weight = q
+				}
+			}
+		}
+		weights[name] = weight
+	}
+	var (
+		best       CompressionEncoder
+		bestWeight float64
+	)
+	for _, encoder := range encoders {
+		weight, ok := weights[encoder.Encoding()]
+		if !ok {
+			weight = weights["*"]
+		}
+		if weight > bestWeight {
+			best, bestWeight = encoder, weight
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the beginning of response body to decide whether it
+// should be compressed. It implements the optional interfaces of response writer,
+// the ones that are not supported by original writer are hidden by wrapWriter
+// (but still can be reached with Unwrap, so they have to be checked).
+type compressWriter struct {
+	http.ResponseWriter
+	encoder CompressionEncoder
+	minSize int
+	code    int
+	buf     []byte
+	decided bool
+	writer  io.WriteCloser
+}
+
+// WriteHeader postpones sending the headers until compression decision is made.
+func (cw *compressWriter) WriteHeader(code int) {
+	// informational responses and superfluous calls are passed as is
+	if code < http.StatusOK || cw.decided {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.code == 0 {
+		cw.code = code
+	}
+}
+
+// Write buffers the data until minimal size is reached and compresses the rest.
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		if cw.code == 0 {
+			cw.code = http.StatusOK
+		}
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) >= cw.minSize {
+			if err := cw.start(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+	if cw.writer != nil {
+		return cw.writer.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// start sends the headers and buffered data (compressed if possible).
+func (cw *compressWriter) start(large bool) error {
+	cw.decided = true
+	header := cw.ResponseWriter.Header()
+	if _, ok := header[contentTypeHeader]; !ok && len(cw.buf) > 0 {
+		// content type should be detected before compression
+		header.Set(contentTypeHeader, http.DetectContentType(cw.buf))
+	}
+	if large && cw.compressible(header) {
+		header.Set(contentEncodingHeader, cw.encoder.Encoding())
+		header.Del("Content-Length")
+		cw.ResponseWriter.WriteHeader(cw.code)
+		cw.writer = cw.encoder.Writer(cw.ResponseWriter)
+		_, err := cw.writer.Write(cw.buf)
+		cw.buf = nil
+		return err
+	}
+	cw.ResponseWriter.WriteHeader(cw.code)
+	_, err := cw.ResponseWriter.Write(cw.buf)
+	cw.buf = nil
+	return err
+}
+
+// compressible checks if the response can be compressed.
+func (cw *compressWriter) compressible(header http.Header) bool {
+	if cw.code == http.StatusNoContent || cw.code == http.StatusNotModified {
+		return false
+	}
+	if header.Get(contentEncodingHeader) != "" {
+		return false
+	}
+	ctype := strings.ToLower(header.Get(contentTypeHeader))
+	for _, prefix := range incompressibleTypes {
+		if strings.HasPrefix(ctype, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// close sends buffered data (if any) and finishes compression.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		// nothing was written - net/http is going to send default response
+		if cw.code == 0 {
+			return
+		}
+		cw.start(false)
+	}
+	if cw.writer != nil {
+		cw.writer.Close()
+	}
+}
+
+// Flush sends buffered data to the client (compression is started regardless
+// of minimal size since the response is being streamed).
+func (cw *compressWriter) Flush() {
+	if !cw.decided && cw.code != 0 {
+		cw.start(true)
+	}
+	if flusher, ok := cw.writer.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection (if supported by underlying
+// response writer).
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := cw.ResponseWriter.(http.Hijacker); ok {
+		// the response is not going to be sent by the middleware
+		cw.decided = true
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("%T does not implement http.Hijacker", cw.ResponseWriter)
+}
+
+// Push initiates HTTP/2 server push (if supported by underlying response writer).
+func (cw *compressWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := cw.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the original writer (to be used by http.ResponseController).
+func (cw *compressWriter) Unwrap() http.ResponseWriter { return cw.ResponseWriter }
+
+// ReadFrom copies the data through Write (in order to compress it).
+func (cw *compressWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{cw}, r)
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Compress(t *testing.T) {
+	large := strings.Repeat("compress me ", 100)
+
+	type testCase struct {
+		title    string
+		accept   string
+		ctype    string
+		body     string
+		flush    bool
+		encoding string
+	}
+
+	cases := []testCase{
+		{
+			title: "should not compress response if client does not accept any encoding",
+			body:  large,
+		},
+		{
+			title:    "should compress large response with gzip",
+			accept:   "gzip, deflate",
+			body:     large,
+			encoding: "gzip",
+		},
+		{
+			title:    "should pick the encoding with the highest quality value",
+			accept:   "gzip;q=0.5, deflate",
+			body:     large,
+			encoding: "deflate",
+		},
+		{
+			title:    "should use wildcard quality value",
+			accept:   "gzip;q=0, *",
+			body:     large,
+			encoding: "deflate",
+		},
+		{
+			title:  "should not compress small response",
+			accept: "gzip",
+			body:   "small",
+		},
+		{
+			title:    "should compress small response if it is being flushed",
+			accept:   "gzip",
+			body:     "small",
+			flush:    true,
+			encoding: "gzip",
+		},
+		{
+			title:  "should not compress already compressed content",
+			accept: "gzip",
+			ctype:  "image/png",
+			body:   large,
+		},
+		{
+			title:  "should not compress if no encoding is acceptable",
+			accept: "br",
+			body:   large,
+		},
+	}
+
+	handler := Compress(0)
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "", nil)
+			if tc.accept != "" {
+				r.Header.Set(acceptEncodingHeader, tc.accept)
+			}
+			w := httptest.NewRecorder()
+			handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.ctype != "" {
+					w.Header().Set(contentTypeHeader, tc.ctype)
+				}
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(tc.body))
+				if tc.flush {
+					w.(http.Flusher).Flush()
+				}
+			})).ServeHTTP(w, r)
+			if w.Code != http.StatusCreated {
+				t.Errorf("status code %d was expected to be %d", w.Code, http.StatusCreated)
+			}
+			if vary := w.Header().Get(varyHeader); vary != acceptEncodingHeader {
+				t.Errorf("Vary header %q was expected to be %q", vary, acceptEncodingHeader)
+			}
+			if encoding := w.Header().Get(contentEncodingHeader); encoding != tc.encoding {
+				t.Fatalf("content encoding %q was expected to be %q", encoding, tc.encoding)
+			}
+			var reader io.Reader = w.Body
+			switch tc.encoding {
+			case "gzip":
+				reader, _ = gzip.NewReader(w.Body)
+			case "deflate":
+				reader, _ = zlib.NewReader(w.Body)
+			}
+			body, err := ioutil.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("cannot read response body: %s", err)
+			}
+			if string(body) != tc.body {
+				t.Errorf("response body %q was expected to be %q", body, tc.body)
+			}
+		})
+	}
+
+	t.Run("should not send anything if handler did not write the response", func(t *testing.T) {
+		r, _ := http.NewRequest(http.MethodGet, "", nil)
+		r.Header.Set(acceptEncodingHeader, "gzip")
+		w := httptest.NewRecorder()
+		handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(w, r)
+		if w.Header().Get(contentEncodingHeader) != "" || w.Body.Len() != 0 {
+			t.Error("response should be empty")
+		}
+	})
+
+	t.Run("should expose only optional interfaces of original writer", func(t *testing.T) {
+		r, _ := http.NewRequest(http.MethodGet, "", nil)
+		r.Header.Set(acceptEncodingHeader, "gzip")
+		for _, tc := range []struct {
+			w       http.ResponseWriter
+			flusher bool
+		}{
+			{httptest.NewRecorder(), true},
+			{struct{ http.ResponseWriter }{httptest.NewRecorder()}, false},
+		} {
+			handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, ok := w.(http.Flusher); ok != tc.flusher {
+					t.Errorf("the writer was expected to implement http.Flusher: %t", tc.flusher)
+				}
+				if _, ok := w.(http.Hijacker); ok {
+					t.Error("the writer was not expected to implement http.Hijacker")
+				}
+			})).ServeHTTP(tc.w, r)
+		}
+	})
+
+	t.Run("should panic with invalid compression level", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("the code should panic")
+			}
+		}()
+		GzipEncoder(100)
+	})
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Predicate decides whether the middleware should be applied to the request.
+type Predicate func(*http.Request) bool
+
+// When applies the middleware only to the requests matching the predicate, the
+// rest of the requests are passed directly to the next handler.
+//
+// Example:
+//
+//	mw.New(
+//	    mw.RequestID,
+//	    mw.JWT(parser, claims).Unless(mw.PathPrefix("/health")),
+//	    mw.Codec(nil, codecs).Unless(mw.Methods(http.MethodOptions)),
+//	).Then(mux)
+func (mw Middleware) When(pred Predicate) Middleware {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if pred(r) {
+				wrapped.ServeHTTP(w, r)
+			} else {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// Unless applies the middleware to all the requests except the ones matching
+// the predicate.
+func (mw Middleware) Unless(pred Predicate) Middleware {
+	return mw.When(Not(pred))
+}
+
+// Not inverts the predicate.
+func Not(pred Predicate) Predicate {
+	return func(r *http.Request) bool { return !pred(r) }
+}
+
+// All matches the request if all the predicates match it.
+func All(preds ...Predicate) Predicate {
+	return func(r *http.Request) bool {
+		for _, pred := range preds {
+			if !pred(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any matches the request if at least one of the predicates matches it.
+func Any(preds ...Predicate) Predicate {
+	return func(r *http.Request) bool {
+		for _, pred := range preds {
+			if pred(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// PathPrefix matches the requests with URL path starting with one of the prefixes.
+func PathPrefix(prefixes ...string) Predicate {
+	return func(r *http.Request) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// PathGlob matches the requests with URL path matching one of the shell patterns
+// (see path.Match), it panics if any of the patterns is malformed.
+func PathGlob(patterns ...string) Predicate {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			panic(err.Error())
+		}
+	}
+	return func(r *http.Request) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, r.URL.Path); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// PathRegexp matches the requests with URL path matching the regular expression,
+// it panics if the expression cannot be parsed.
+func PathRegexp(expr string) Predicate {
+	re := regexp.MustCompile(expr)
+	return func(r *http.Request) bool {
+		return re.MatchString(r.URL.Path)
+	}
+}
+
+// Methods matches the requests with one of provided HTTP methods.
+func Methods(methods ...string) Predicate {
+	set := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		set[strings.ToUpper(method)] = struct{}{}
+	}
+	return func(r *http.Request) bool {
+		_, ok := set[r.Method]
+		return ok
+	}
+}
+
+// HeaderPresent matches the requests containing the header (even if it is empty).
+func HeaderPresent(name string) Predicate {
+	name = http.CanonicalHeaderKey(name)
+	return func(r *http.Request) bool {
+		_, ok := r.Header[name]
+		return ok
+	}
+}
+
+// Host matches the requests to one of provided hosts (port is ignored unless it
+// is specified in the host).
+func Host(hosts ...string) Predicate {
+	return func(r *http.Request) bool {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		for _, expected := range hosts {
+			if strings.EqualFold(expected, host) || strings.EqualFold(expected, r.Host) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Predicates(t *testing.T) {
+	type testCase struct {
+		title   string
+		pred    Predicate
+		request func() *http.Request
+		match   bool
+	}
+
+	request := func(method, url string, headers map[string]string) func() *http.Request {
+		return func() *http.Request {
+			r := httptest.NewRequest(method, url, nil)
+			for key, value := range headers {
+				r.Header.Set(key, value)
+			}
+			return r
+		}
+	}
+
+	cases := []testCase{
+		{
+			title:   "path prefix should match",
+			pred:    PathPrefix("/api", "/health"),
+			request: request(http.MethodGet, "/health/live", nil),
+			match:   true,
+		},
+		{
+			title:   "path prefix should not match",
+			pred:    PathPrefix("/api"),
+			request: request(http.MethodGet, "/health", nil),
+		},
+		{
+			title:   "path glob should match",
+			pred:    PathGlob("/users/*/avatar"),
+			request: request(http.MethodGet, "/users/42/avatar", nil),
+			match:   true,
+		},
+		{
+			title:   "path glob should not match",
+			pred:    PathGlob("/users/*"),
+			request: request(http.MethodGet, "/users/42/avatar", nil),
+		},
+		{
+			title:   "path regexp should match",
+			pred:    PathRegexp(`^/v\d+/`),
+			request: request(http.MethodGet, "/v2/users", nil),
+			match:   true,
+		},
+		{
+			title:   "method should match",
+			pred:    Methods("get", http.MethodHead),
+			request: request(http.MethodGet, "/", nil),
+			match:   true,
+		},
+		{
+			title:   "method should not match",
+			pred:    Methods(http.MethodOptions),
+			request: request(http.MethodGet, "/", nil),
+		},
+		{
+			title:   "header presence should match",
+			pred:    HeaderPresent("x-debug"),
+			request: request(http.MethodGet, "/", map[string]string{"X-Debug": ""}),
+			match:   true,
+		},
+		{
+			title:   "host should match ignoring the port",
+			pred:    Host("example.com"),
+			request: request(http.MethodGet, "http://EXAMPLE.com:8080/", nil),
+			match:   true,
+		},
+		{
+			title:   "host should not match",
+			pred:    Host("example.com:9090"),
+			request: request(http.MethodGet, "http://example.com:8080/", nil),
+		},
+		{
+			title:   "all predicates should match",
+			pred:    All(PathPrefix("/api"), Methods(http.MethodPost)),
+			request: request(http.MethodGet, "/api", nil),
+		},
+		{
+			title:   "any of predicates should match",
+			pred:    Any(PathPrefix("/api"), Methods(http.MethodPost)),
+			request: request(http.MethodGet, "/api", nil),
+			match:   true,
+		},
+		{
+			title:   "inverted predicate should not match",
+			pred:    Not(PathPrefix("/api")),
+			request: request(http.MethodGet, "/api", nil),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			if match := tc.pred(tc.request()); match != tc.match {
+				t.Errorf("the result %t was expected to be %t", match, tc.match)
+			}
+		})
+	}
+}
+
+func Test_Middleware_When(t *testing.T) {
+	type testCase struct {
+		title   string
+		handler http.Handler
+		path    string
+		out     string
+	}
+
+	cases := []testCase{
+		{
+			title:   "should apply middleware when predicate matches",
+			handler: New(Middleware(middlewareOne).When(PathPrefix("/api"))).Then(handlerFinal),
+			path:    "/api/users",
+			out:     "/mw1 before next/final handler/mw1 after next",
+		},
+		{
+			title:   "should skip middleware when predicate does not match",
+			handler: New(Middleware(middlewareOne).When(PathPrefix("/api"))).Then(handlerFinal),
+			path:    "/health",
+			out:     "/final handler",
+		},
+		{
+			title:   "should skip middleware unless predicate matches",
+			handler: New(middlewareOne, Middleware(middlewareTwo).Unless(PathPrefix("/health"))).Then(handlerFinal),
+			path:    "/health",
+			out:     "/mw1 before next/final handler/mw1 after next",
+		},
+		{
+			title:   "conditional middleware should be supported by Chain",
+			handler: Chain(Middleware(middlewareOne).Unless(PathPrefix("/health")), handlerFinal),
+			path:    "/api",
+			out:     "/mw1 before next/final handler",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tc.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if !strings.HasPrefix(w.Body.String(), tc.out) {
+				t.Errorf("the output %q is expected to start with %q", w.Body.String(), tc.out)
+			}
+		})
+	}
+}
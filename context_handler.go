@@ -3,35 +3,55 @@ package middleware
 import (
 	"context"
 	"net/http"
+
+	"github.com/tiny-go/errors"
 )
 
 // StatusNoResponse is returned when request is canceled
 const StatusNoResponse = 444
 
-// ContextHandler reads from context.Done channel to handle deadline/timeout
+// ContextHandler reads from context.Done channel to handle deadline/timeout. The
+// next handler is running in a separate goroutine and its response is buffered,
+// it is sent to the client once the handler is done. If the context is done before
+// that (or the handler returns on done context without writing anything), the
+// buffered response is discarded, the error (408 on deadline, 444 on cancellation)
+// is sent instead and all the further writes of the handler fail with
+// http.ErrHandlerTimeout. Streaming handlers (e.g. NewStream) can flush the response,
+// it is sent on the first flush and written directly after that, so the error
+// cannot be sent anymore if the context is done later (the response is just
+// interrupted).
 func ContextHandler(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		processed := make(chan struct{})
-		go func() {
-			defer close(processed)
-			next.ServeHTTP(w, r)
-		}()
-		select {
-		case <-r.Context().Done():
-			switch r.Context().Err() {
-			case nil:
-				// do nothing
+	return NewContextHandler(nil)(next)
+}
+
+// NewContextHandler creates ContextHandler middleware which sends the errors with
+// provided errors.HandlerFunc (http.Error by default).
+func NewContextHandler(fn errors.HandlerFunc) Middleware {
+	if fn == nil {
+		fn = http.Error
+	}
+	return func(next http.Handler) http.Handler {
+		document(next, APIDoc{Responses: []int{http.StatusRequestTimeout}})
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gw, finished := serveGuarded(w, r, next, true)
+			// handler may give up on done context without writing the response
+			if finished && (r.Context().Err() == nil || gw.written()) {
+				gw.commit()
+				return
+			}
+			// the response has been partially sent already
+			if gw.streaming() {
+				return
+			}
+			switch err := r.Context().Err(); err {
 			case context.Canceled:
-				http.Error(w, r.Context().Err().Error(), StatusNoResponse)
+				sendError(fn, w, r, err.Error(), StatusNoResponse)
 			case context.DeadlineExceeded:
-				http.Error(w, r.Context().Err().Error(), http.StatusRequestTimeout)
+				sendError(fn, w, r, err.Error(), http.StatusRequestTimeout)
 			default:
 				// handle unknown errors
-				http.Error(w, r.Context().Err().Error(), http.StatusInternalServerError)
+				sendError(fn, w, r, err.Error(), http.StatusInternalServerError)
 			}
-			return
-		case <-processed:
-			return
-		}
-	})
+		})
+	}
 }
@@ -49,6 +49,24 @@ func Test_ContextExceeder(t *testing.T) {
 		}
 	})
 
+	t.Run("should send an error with provided error handler", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("", "", nil)
+
+		ctx, fn := context.WithDeadline(context.Background(), time.Time{})
+		r = r.WithContext(ctx)
+		defer fn()
+
+		handler := NewContextHandler(ProblemError)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusRequestTimeout {
+			t.Errorf("response code was expected to be 408, got %d", w.Code)
+		}
+		if ctype := w.Header().Get(contentTypeHeader); ctype != MimeTypeProblemJSON {
+			t.Errorf("content type %q was expected to be %q", ctype, MimeTypeProblemJSON)
+		}
+	})
+
 	t.Run("should not return an error if request was processed in given time", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		r, _ := http.NewRequest("", "", nil)
@@ -65,3 +83,114 @@ func Test_ContextExceeder(t *testing.T) {
 		}
 	})
 }
+
+func Test_ContextHandler_guardedWrites(t *testing.T) {
+	t.Run("should discard the response of the handler and fail late writes", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+
+		lateErr := make(chan error, 1)
+		handler := ContextHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Handler", "true")
+			w.Write([]byte("partial"))
+			<-r.Context().Done()
+			time.Sleep(10 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+			_, err := w.Write([]byte("late"))
+			lateErr <- err
+		}))
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusRequestTimeout {
+			t.Errorf("response code was expected to be 408, got %d", w.Code)
+		}
+		if w.Body.String() != "context deadline exceeded\n" {
+			t.Errorf("the response %q was expected to be \"context deadline exceeded\n\"", w.Body.String())
+		}
+		if err := <-lateErr; err != http.ErrHandlerTimeout {
+			t.Errorf("late write error %v was expected to be %v", err, http.ErrHandlerTimeout)
+		}
+		if w.Header().Get("X-Handler") != "" {
+			t.Error("the headers of discarded response should not be sent")
+		}
+	})
+
+	t.Run("should send either the response or the error when context is done concurrently", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			w := httptest.NewRecorder()
+			ctx, cancel := context.WithCancel(context.Background())
+			r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+			handler := ContextHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				go cancel()
+				w.Header().Set("X-Handler", "true")
+				w.Write([]byte("success\n"))
+			}))
+			handler.ServeHTTP(w, r)
+			cancel()
+			switch {
+			case w.Code == http.StatusOK && w.Body.String() == "success\n" && w.Header().Get("X-Handler") == "true":
+			case w.Code == StatusNoResponse && w.Body.String() == "context canceled\n":
+			default:
+				t.Fatalf("unexpected response: %d %q", w.Code, w.Body.String())
+			}
+		}
+	})
+}
+
+func Test_ContextHandler_Flush(t *testing.T) {
+	t.Run("should send the response on the first flush and stream the rest", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler := ContextHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(contentTypeHeader, MimeTypeEventStream)
+			w.Write([]byte("first\n"))
+			w.(http.Flusher).Flush()
+			if rec := w.(*guardedWriter).w.(*httptest.ResponseRecorder); !rec.Flushed || rec.Body.String() != "first\n" {
+				t.Error("the response was expected to be sent on flush")
+			}
+			w.Write([]byte("second\n"))
+		}))
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("response code was expected to be 200, got %d", w.Code)
+		}
+		if ctype := w.Header().Get(contentTypeHeader); ctype != MimeTypeEventStream {
+			t.Errorf("content type %q was expected to be %q", ctype, MimeTypeEventStream)
+		}
+		if w.Body.String() != "first\nsecond\n" {
+			t.Errorf("the response %q was expected to be \"first\nsecond\n\"", w.Body.String())
+		}
+	})
+
+	t.Run("should interrupt flushed response when the context is done", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+
+		lateErr := make(chan error, 1)
+		handler := ContextHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			time.Sleep(10 * time.Millisecond)
+			_, err := w.Write([]byte("late"))
+			lateErr <- err
+		}))
+		handler.ServeHTTP(w, r)
+		if err := <-lateErr; err != http.ErrHandlerTimeout {
+			t.Errorf("late write error %v was expected to be %v", err, http.ErrHandlerTimeout)
+		}
+		if w.Code != http.StatusOK || w.Body.String() != "partial" {
+			t.Errorf("unexpected response: %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("should not allow flushing if original writer does not support it", func(t *testing.T) {
+		ContextHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := w.(http.Flusher); ok {
+				t.Error("the writer was not expected to implement http.Flusher")
+			}
+		})).ServeHTTP(struct{ http.ResponseWriter }{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
@@ -1,5 +1,15 @@
 package middleware
 
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// AnyMethod is a wildcard method, the middleware registered for it is applied to
+// all the methods before the method-specific middleware.
+const AnyMethod = "*"
+
 // Controller represents simple HTTP controller containing middleware for each method.
 type Controller interface {
 	// AddMiddleware should make the provided chain available by HTTP method.
@@ -9,9 +19,13 @@ type Controller interface {
 }
 
 // BaseController provides ability to register/unregister middleware for HTTP methods.
-//This is a basic Controller implementation.
+// This is a basic Controller implementation, it is safe for concurrent use (zero
+// value is ready to use).
 type BaseController struct {
+	mu         sync.RWMutex
 	middleware map[string]Middleware
+	// the number of changes of the middleware (see ServeController)
+	changes uint64
 }
 
 // NewBaseController is a constructor func for a basic HTTP controller.
@@ -23,6 +37,12 @@ func NewBaseController() *BaseController {
 
 // AddMiddleware adds middleware funcs to existing ones for provided HTTP method.
 func (bc *BaseController) AddMiddleware(method string, chain ...Middleware) Controller {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.changes++
+	if bc.middleware == nil {
+		bc.middleware = make(map[string]Middleware)
+	}
 	if _, ok := bc.middleware[method]; !ok {
 		// create new middleware
 		bc.middleware[method] = New(chain...)
@@ -34,14 +54,72 @@ func (bc *BaseController) AddMiddleware(method string, chain ...Middleware) Cont
 	return bc
 }
 
-// Middleware returns middleware func registered for provided method or an empty list.
+// SetMiddleware replaces the middleware registered for provided HTTP method.
+func (bc *BaseController) SetMiddleware(method string, chain ...Middleware) Controller {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.changes++
+	if bc.middleware == nil {
+		bc.middleware = make(map[string]Middleware)
+	}
+	bc.middleware[method] = New(chain...)
+	return bc
+}
+
+// ResetMiddleware removes the middleware registered for provided HTTP method.
+func (bc *BaseController) ResetMiddleware(method string) Controller {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.changes++
+	delete(bc.middleware, method)
+	return bc
+}
+
+// Middleware returns middleware func registered for provided method (preceded by
+// the middleware registered for AnyMethod) or an empty list.
 func (bc *BaseController) Middleware(method string) Middleware {
-	if mw, ok := bc.middleware[method]; ok {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	wildcard, wildcardOK := bc.middleware[AnyMethod]
+	mw, ok := bc.middleware[method]
+	switch {
+	case ok && wildcardOK && method != AnyMethod:
+		return wildcard.Use(mw)
+	case ok:
 		return mw
+	case wildcardOK:
+		return wildcard
+	default:
+		return New()
+	}
+}
+
+// Revision returns the number of changes of the middleware (see Revisioned).
+func (bc *BaseController) Revision() uint64 {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.changes
+}
+
+// Methods returns sorted list of HTTP methods with registered middleware (except
+// AnyMethod).
+func (bc *BaseController) Methods() []string {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	methods := make([]string, 0, len(bc.middleware))
+	for method := range bc.middleware {
+		if method != AnyMethod {
+			methods = append(methods, method)
+		}
 	}
-	return New()
+	sort.Strings(methods)
+	return methods
 }
 
 // Init does nothing. This is a default function to avoid explicit declaration
 // when controller does not require any Init logic.
 func (bc *BaseController) Init() error { return nil }
+
+// Shutdown does nothing. This is a default function to avoid explicit declaration
+// when controller does not require any Shutdown logic.
+func (bc *BaseController) Shutdown(context.Context) error { return nil }
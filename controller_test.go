@@ -3,6 +3,8 @@ package middleware
 import (
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -50,5 +52,96 @@ func Test_BaseController(t *testing.T) {
 				}
 			})
 		}
+		t.Run("list of methods with registered middleware", func(t *testing.T) {
+			controller.AddMiddleware(http.MethodDelete)
+			if methods := controller.Methods(); !reflect.DeepEqual(methods, []string{http.MethodDelete, http.MethodGet}) {
+				t.Errorf("the list of methods %v was not expected", methods)
+			}
+		})
 	})
 }
+
+func Test_BaseController_wildcard(t *testing.T) {
+	type testCase struct {
+		title  string
+		setup  func(bc *BaseController)
+		method string
+		out    string
+	}
+
+	cases := []testCase{
+		{
+			title: "should apply wildcard middleware before method-specific one",
+			setup: func(bc *BaseController) {
+				bc.AddMiddleware(http.MethodGet, middlewareTwo)
+				bc.AddMiddleware(AnyMethod, middlewareOne)
+			},
+			method: http.MethodGet,
+			out:    "/mw1 before next/mw2 before next/final handler/mw2 after next/mw1 after next",
+		},
+		{
+			title:  "should apply wildcard middleware to the methods without middleware",
+			setup:  func(bc *BaseController) { bc.AddMiddleware(AnyMethod, middlewareOne) },
+			method: http.MethodPost,
+			out:    "/mw1 before next/final handler/mw1 after next",
+		},
+		{
+			title: "should replace the middleware of the method",
+			setup: func(bc *BaseController) {
+				bc.AddMiddleware(http.MethodGet, middlewareOne, middlewareTwo)
+				bc.SetMiddleware(http.MethodGet, middlewareThree)
+			},
+			method: http.MethodGet,
+			out:    "/mw3 before next/final handler/mw3 after next",
+		},
+		{
+			title: "should remove the middleware of the method",
+			setup: func(bc *BaseController) {
+				bc.AddMiddleware(http.MethodGet, middlewareOne)
+				bc.ResetMiddleware(http.MethodGet)
+			},
+			method: http.MethodGet,
+			out:    "/final handler",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			// zero value should be ready to use
+			controller := &BaseController{}
+			tc.setup(controller)
+			w := httptest.NewRecorder()
+			controller.Middleware(tc.method).Then(handlerFinal).ServeHTTP(w, nil)
+			if w.Body.String() != tc.out {
+				t.Errorf("handler output is expected to be %q but was %q", tc.out, w.Body.String())
+			}
+		})
+	}
+}
+
+func Test_BaseController_concurrency(t *testing.T) {
+	controller := NewBaseController()
+	methods := []string{AnyMethod, http.MethodGet, http.MethodPost, http.MethodDelete}
+	handler := ServeController(controller, map[string]http.Handler{http.MethodGet: handlerFinal})
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		method := methods[i%len(methods)]
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			switch i % 5 {
+			case 0:
+				controller.SetMiddleware(method, middlewareOne)
+			case 1:
+				controller.ResetMiddleware(method)
+			case 2:
+				controller.Methods()
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/", nil))
+			default:
+				controller.AddMiddleware(method, middlewareTwo)
+				controller.Middleware(method).Then(handlerFinal).ServeHTTP(httptest.NewRecorder(), nil)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+)
+
+// guardedWriter buffers the response of the handler which is running in a separate
+// goroutine (similar to http.TimeoutHandler). The response is either copied to the
+// actual writer when handler is done or discarded if handler did not finish in time,
+// all the writes after that fail with http.ErrHandlerTimeout. If flushing is allowed,
+// the response is sent on the first Flush and the data is written directly to the
+// actual writer after that (the response cannot be discarded anymore).
+type guardedWriter struct {
+	mu          sync.Mutex
+	w           http.ResponseWriter
+	header      http.Header
+	buf         bytes.Buffer
+	code        int
+	wroteHeader bool
+	finished    bool
+	timedOut    bool
+	flushed     bool
+}
+
+// newGuardedWriter is a constructor func for guardedWriter.
+func newGuardedWriter(w http.ResponseWriter) *guardedWriter {
+	return &guardedWriter{w: w, header: make(http.Header)}
+}
+
+// Header returns the headers of buffered response.
+func (gw *guardedWriter) Header() http.Header { return gw.header }
+
+// Write buffers the data unless timeout response was sent.
+func (gw *guardedWriter) Write(p []byte) (int, error) {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	if gw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !gw.wroteHeader {
+		gw.writeHeader(http.StatusOK)
+	}
+	if gw.flushed {
+		return gw.w.Write(p)
+	}
+	return gw.buf.Write(p)
+}
+
+// Flush sends the response to the client (it is called only if the actual writer
+// implements http.Flusher, see serveGuarded).
+func (gw *guardedWriter) Flush() {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	if gw.timedOut {
+		return
+	}
+	if !gw.flushed {
+		if !gw.wroteHeader {
+			gw.writeHeader(http.StatusOK)
+		}
+		gw.send()
+		gw.flushed = true
+	}
+	gw.w.(http.Flusher).Flush()
+}
+
+// WriteHeader memorizes the status code of the response.
+func (gw *guardedWriter) WriteHeader(code int) {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	if gw.timedOut || gw.wroteHeader {
+		return
+	}
+	gw.writeHeader(code)
+}
+
+// writeHeader should be called under the lock.
+func (gw *guardedWriter) writeHeader(code int) {
+	gw.wroteHeader, gw.code = true, code
+}
+
+// written reports whether handler has started writing the response.
+func (gw *guardedWriter) written() bool {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	return gw.wroteHeader
+}
+
+// streaming reports whether the response has been already sent by Flush.
+func (gw *guardedWriter) streaming() bool {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	return gw.flushed
+}
+
+// finish marks the handler as finished (unless timeout response has been already sent).
+func (gw *guardedWriter) finish() {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	gw.finished = !gw.timedOut
+}
+
+// timeout seals the writer, it returns false if handler has been already finished
+// (its response should be sent instead).
+func (gw *guardedWriter) timeout() bool {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	gw.timedOut = !gw.finished
+	return gw.timedOut
+}
+
+// commit copies buffered response to the actual writer (it should be called
+// only after handler is finished).
+func (gw *guardedWriter) commit() {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	if !gw.flushed {
+		gw.send()
+	}
+}
+
+// send copies buffered response to the actual writer (it should be called under
+// the lock).
+func (gw *guardedWriter) send() {
+	header := gw.w.Header()
+	for key, value := range gw.header {
+		header[key] = value
+	}
+	if gw.wroteHeader {
+		gw.w.WriteHeader(gw.code)
+	}
+	gw.w.Write(gw.buf.Bytes())
+	gw.buf.Reset()
+}
+
+// serveGuarded calls the handler in a separate goroutine with guarded writer and
+// waits until handler is done or request context is done. It returns false if
+// handler did not finish in time - in that case the response should be sent by
+// the caller directly to the original writer (unless the response has been already
+// flushed). Panics are propagated to the caller goroutine. The handler can flush
+// the response only if flush is true and the original writer supports it.
+func serveGuarded(w http.ResponseWriter, r *http.Request, next http.Handler, flush bool) (*guardedWriter, bool) {
+	gw := newGuardedWriter(w)
+	// hide Flush method of guarded writer
+	var rw http.ResponseWriter = struct{ http.ResponseWriter }{gw}
+	if _, ok := w.(http.Flusher); ok && flush {
+		rw = gw
+	}
+	done := make(chan struct{})
+	panicChan := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicChan <- p
+			}
+		}()
+		next.ServeHTTP(rw, r)
+		gw.finish()
+		close(done)
+	}()
+	select {
+	case p := <-panicChan:
+		panic(p)
+	case <-done:
+		return gw, true
+	case <-r.Context().Done():
+		if gw.timeout() {
+			return gw, false
+		}
+		// handler has just finished
+		<-done
+		return gw, true
+	}
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/tiny-go/errors"
+)
+
+// HandlerE is an HTTP handler that returns an error instead of sending it to the
+// client, the error is sent by RenderError (or the renderer provided with WithRenderer)
+// unless the response has been already written by the handler.
+type HandlerE func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP calls the handler and renders the returned error (to satisfy http.Handler
+// interface).
+func (h HandlerE) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r)
+}
+
+// Middleware converts the handler to Middleware which calls the next handler only
+// if the current one did not return an error.
+func (h HandlerE) Middleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if h.serve(w, r) == nil {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// WithRenderer returns the handler which sends its errors with provided renderer
+// instead of RenderError (the error is still returned in order to break the chain).
+//
+// Example:
+//
+//	mw.Chain(mw.HandlerE(authorize).WithRenderer(mw.RenderProblem), handler)
+func (h HandlerE) WithRenderer(render ErrorRenderer) HandlerE {
+	if render == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return h.render(w, r, render)
+	}
+}
+
+// serve calls the handler and renders the error (if any), the error is returned
+// to the caller to decide whether the chain should be continued.
+func (h HandlerE) serve(w http.ResponseWriter, r *http.Request) error {
+	return h.render(w, r, RenderError)
+}
+
+// render calls the handler and renders the error with provided renderer unless
+// the response has been already written.
+func (h HandlerE) render(w http.ResponseWriter, r *http.Request, render ErrorRenderer) error {
+	rw := NewResponseWriter(w)
+	err := h(rw, r)
+	if err != nil && !rw.Written() {
+		render(rw, r, err)
+	}
+	return err
+}
+
+// ErrorRenderer sends the error returned by HandlerE to the client.
+type ErrorRenderer func(w http.ResponseWriter, r *http.Request, err error)
+
+// RenderError sends the error as plain text using the status code of errors.Error
+// (and its wrappers), context errors are sent as 408 (deadline exceeded) or 444
+// (canceled). The message of other errors is not exposed to the client and they
+// are sent as 500 (Internal Server Error).
+func RenderError(w http.ResponseWriter, r *http.Request, err error) {
+	renderError(err, func(message string, code int) {
+		http.Error(w, message, code)
+	})
+}
+
+// RenderProblem is the same as RenderError but it sends RFC 7807 problem details
+// encoded with the negotiated response codec (see SendProblem).
+func RenderProblem(w http.ResponseWriter, r *http.Request, err error) {
+	renderError(err, func(message string, code int) {
+		SendProblem(w, r, NewProblem(r, message, code))
+	})
+}
+
+// renderError resolves the message and status code of the error and sends them.
+func renderError(err error, send func(message string, code int)) {
+	var withCode errors.Error
+	switch {
+	case stderrors.As(err, &withCode):
+		send(withCode.Error(), withCode.Code())
+	case stderrors.Is(err, context.DeadlineExceeded):
+		send(err.Error(), http.StatusRequestTimeout)
+	case stderrors.Is(err, context.Canceled):
+		send(err.Error(), StatusNoResponse)
+	default:
+		send(http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tiny-go/errors"
+)
+
+func Test_HandlerE(t *testing.T) {
+	type testCase struct {
+		title   string
+		handler http.Handler
+		code    int
+		body    string
+	}
+
+	handlerNext := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("next"))
+	})
+
+	cases := []testCase{
+		{
+			title: "should call next handler if error was not returned",
+			handler: Chain(func(w http.ResponseWriter, r *http.Request) error {
+				w.Header().Set("X-Checked", "true")
+				return nil
+			}, handlerNext),
+			code: http.StatusOK,
+			body: "next",
+		},
+		{
+			title: "should send the status code of errors.Error and break the chain",
+			handler: Chain(func(w http.ResponseWriter, r *http.Request) error {
+				return errors.Unauthorized("invalid token")
+			}, handlerNext),
+			code: http.StatusUnauthorized,
+			body: "invalid token\n",
+		},
+		{
+			title: "should send the status code of wrapped errors.Error",
+			handler: Chain(HandlerE(func(w http.ResponseWriter, r *http.Request) error {
+				return fmt.Errorf("cannot find user: %w", errors.NotFound("not found"))
+			}), handlerNext),
+			code: http.StatusNotFound,
+			body: "not found\n",
+		},
+		{
+			title: "should send context errors with corresponding status codes",
+			handler: HandlerE(func(w http.ResponseWriter, r *http.Request) error {
+				return fmt.Errorf("cannot fetch data: %w", context.DeadlineExceeded)
+			}),
+			code: http.StatusRequestTimeout,
+			body: "cannot fetch data: context deadline exceeded\n",
+		},
+		{
+			title: "should not expose the message of unknown errors",
+			handler: New(HandlerE(func(w http.ResponseWriter, r *http.Request) error {
+				return fmt.Errorf("database password is incorrect")
+			}).Middleware()).Then(handlerNext),
+			code: http.StatusInternalServerError,
+			body: "Internal Server Error\n",
+		},
+		{
+			title: "should not render the error if response has been already written",
+			handler: Chain(func(w http.ResponseWriter, r *http.Request) error {
+				w.WriteHeader(http.StatusConflict)
+				return errors.BadRequest("bad request")
+			}, handlerNext),
+			code: http.StatusConflict,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tc.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tc.code {
+				t.Errorf("status code %d was expected to be %d", w.Code, tc.code)
+			}
+			if w.Body.String() != tc.body {
+				t.Errorf("the body %q was expected to be %q", w.Body.String(), tc.body)
+			}
+		})
+	}
+
+	t.Run("should render errors with custom error renderer", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		Chain(HandlerE(func(w http.ResponseWriter, r *http.Request) error {
+			return fmt.Errorf("failure")
+		}).WithRenderer(func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, "custom: "+err.Error(), http.StatusTeapot)
+		}), handlerFinal).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusTeapot {
+			t.Errorf("status code %d was expected to be %d", w.Code, http.StatusTeapot)
+		}
+		if body := "custom: failure\n"; w.Body.String() != body {
+			t.Errorf("the body %q was expected to be %q", w.Body.String(), body)
+		}
+	})
+
+	t.Run("should render errors as problem details", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		HandlerE(func(w http.ResponseWriter, r *http.Request) error {
+			return errors.NotFound("user not found")
+		}).WithRenderer(RenderProblem).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("status code %d was expected to be %d", w.Code, http.StatusNotFound)
+		}
+		if ctype := w.Header().Get(contentTypeHeader); ctype != MimeTypeProblemJSON {
+			t.Errorf("content type %q was expected to be %q", ctype, MimeTypeProblemJSON)
+		}
+	})
+}
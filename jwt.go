@@ -32,19 +32,29 @@ type ClaimsFactory func() Claims
 // JWT is a JSON Web token middleware that parses token with provided parser
 // to the provided Claims receiver and puts it to the request context.
 func JWT(parser JWTParser, cf ClaimsFactory) Middleware {
+	return NewJWT(nil, parser, cf)
+}
+
+// NewJWT creates JWT middleware which sends the errors with provided errors.HandlerFunc
+// (http.Error by default).
+func NewJWT(fn errors.HandlerFunc, parser JWTParser, cf ClaimsFactory) Middleware {
+	if fn == nil {
+		fn = http.Error
+	}
 	return func(next http.Handler) http.Handler {
+		document(next, jwtDoc)
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// get JSON web token from the request
 			bearer, ok := Bearer(r)
 			if !ok {
-				http.Error(w, "no JSON web token in request", http.StatusUnauthorized)
+				sendError(fn, w, r, "no JSON web token in request", http.StatusUnauthorized)
 				return
 			}
 			// instantiate an empty claims
 			claims := cf()
 			// validate token
 			if err := parser.Parse(bearer, &claims); err != nil {
-				errors.Send(w, errors.NewUnauthorized(err))
+				sendError(fn, w, r, err.Error(), http.StatusUnauthorized)
 				return
 			}
 			// add claims to the context and call the next
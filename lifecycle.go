@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Initializer is implemented by controllers (and other components) that should
+// be initialized at the startup (see Lifecycle).
+type Initializer interface {
+	Init() error
+}
+
+// Shutdowner is implemented by controllers (and other components) that should
+// release their resources on graceful server stop (see Lifecycle).
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// component is a registered member of the Lifecycle.
+type component struct {
+	name      string
+	value     interface{}
+	dependsOn []string
+}
+
+// Lifecycle initializes registered components (controllers) in dependency order
+// and shuts them down in reverse order.
+//
+// Example:
+//
+//	lc := mw.NewLifecycle().
+//	    Register("db", db).
+//	    Register("users", users, "db")
+//	if err := lc.Init(); err != nil {
+//	    log.Fatal(err)
+//	}
+//	// on SIGTERM
+//	lc.ShutdownServer(ctx, server)
+type Lifecycle struct {
+	mu          sync.Mutex
+	components  []component
+	initialized []component
+}
+
+// NewLifecycle is a constructor func for Lifecycle.
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// Register adds the component which depends on the components with provided names
+// (they are initialized before it). The component may implement Initializer and/or
+// Shutdowner interfaces.
+func (lc *Lifecycle) Register(name string, value interface{}, dependsOn ...string) *Lifecycle {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.components = append(lc.components, component{name: name, value: value, dependsOn: dependsOn})
+	return lc
+}
+
+// order sorts the components topologically keeping the order of registration
+// for independent components.
+func (lc *Lifecycle) order() ([]component, error) {
+	byName := make(map[string]component, len(lc.components))
+	for _, c := range lc.components {
+		if _, ok := byName[c.name]; ok {
+			return nil, fmt.Errorf("component %q is registered more than once", c.name)
+		}
+		byName[c.name] = c
+	}
+	const (
+		visiting = iota + 1
+		visited
+	)
+	var (
+		state  = make(map[string]int, len(lc.components))
+		sorted = make([]component, 0, len(lc.components))
+		path   []string
+		visit  func(c component) error
+	)
+	visit = func(c component) error {
+		switch state[c.name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("circular dependency: %s -> %s", strings.Join(path, " -> "), c.name)
+		}
+		state[c.name] = visiting
+		path = append(path, c.name)
+		for _, name := range c.dependsOn {
+			dep, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("component %q depends on unknown component %q", c.name, name)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[c.name] = visited
+		sorted = append(sorted, c)
+		return nil
+	}
+	for _, c := range lc.components {
+		if err := visit(c); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// Init initializes the components in dependency order. If any of them fails the
+// initialization is aborted and the components that have been already initialized
+// are shut down.
+func (lc *Lifecycle) Init() error {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	sorted, err := lc.order()
+	if err != nil {
+		return err
+	}
+	for _, c := range sorted {
+		if initializer, ok := c.value.(Initializer); ok {
+			if err := initializer.Init(); err != nil {
+				lc.shutdown(context.Background())
+				return fmt.Errorf("cannot initialize %q: %v", c.name, err)
+			}
+		}
+		lc.initialized = append(lc.initialized, c)
+	}
+	return nil
+}
+
+// Shutdown shuts down initialized components in reverse order. All the components
+// are shut down even if some of them fail, the first error is returned.
+func (lc *Lifecycle) Shutdown(ctx context.Context) error {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	return lc.shutdown(ctx)
+}
+
+// shutdown should be called under the lock.
+func (lc *Lifecycle) shutdown(ctx context.Context) (err error) {
+	for i := len(lc.initialized) - 1; i >= 0; i-- {
+		c := lc.initialized[i]
+		if shutdowner, ok := c.value.(Shutdowner); ok {
+			if e := shutdowner.Shutdown(ctx); e != nil && err == nil {
+				err = fmt.Errorf("cannot shut down %q: %v", c.name, e)
+			}
+		}
+	}
+	lc.initialized = nil
+	return err
+}
+
+// ShutdownServer gracefully stops the server (see http.Server.Shutdown) and then
+// shuts down the components (all of them share provided context).
+func (lc *Lifecycle) ShutdownServer(ctx context.Context, server *http.Server) error {
+	serverErr := server.Shutdown(ctx)
+	if err := lc.Shutdown(ctx); err != nil {
+		return err
+	}
+	return serverErr
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+var (
+	_ Initializer = (*BaseController)(nil)
+	_ Shutdowner  = (*BaseController)(nil)
+)
+
+// lifecycleComponent records the calls of Init/Shutdown.
+type lifecycleComponent struct {
+	name        string
+	calls       *[]string
+	initErr     error
+	shutdownErr error
+}
+
+func (lc *lifecycleComponent) Init() error {
+	*lc.calls = append(*lc.calls, "init "+lc.name)
+	return lc.initErr
+}
+
+func (lc *lifecycleComponent) Shutdown(context.Context) error {
+	*lc.calls = append(*lc.calls, "shutdown "+lc.name)
+	return lc.shutdownErr
+}
+
+func Test_Lifecycle(t *testing.T) {
+	type testCase struct {
+		title    string
+		register func(lc *Lifecycle, calls *[]string)
+		initErr  string
+		stopErr  string
+		calls    string
+	}
+
+	component := func(name string, calls *[]string) *lifecycleComponent {
+		return &lifecycleComponent{name: name, calls: calls}
+	}
+
+	cases := []testCase{
+		{
+			title: "should initialize components in dependency order and shut them down in reverse order",
+			register: func(lc *Lifecycle, calls *[]string) {
+				lc.Register("users", component("users", calls), "db", "cache").
+					Register("db", component("db", calls)).
+					Register("health", NewBaseController()).
+					Register("cache", component("cache", calls), "db")
+			},
+			calls: "init db, init cache, init users, shutdown users, shutdown cache, shutdown db",
+		},
+		{
+			title: "should abort initialization and shut down initialized components",
+			register: func(lc *Lifecycle, calls *[]string) {
+				broken := component("cache", calls)
+				broken.initErr = errors.New("connection refused")
+				lc.Register("db", component("db", calls)).
+					Register("cache", broken, "db").
+					Register("users", component("users", calls), "cache")
+			},
+			initErr: "cannot initialize \"cache\": connection refused",
+			calls:   "init db, init cache, shutdown db",
+		},
+		{
+			title: "should shut down all the components returning the first error",
+			register: func(lc *Lifecycle, calls *[]string) {
+				broken := component("cache", calls)
+				broken.shutdownErr = errors.New("timeout")
+				lc.Register("db", component("db", calls)).
+					Register("cache", broken, "db")
+			},
+			stopErr: "cannot shut down \"cache\": timeout",
+			calls:   "init db, init cache, shutdown cache, shutdown db",
+		},
+		{
+			title: "should fail on unknown dependency",
+			register: func(lc *Lifecycle, calls *[]string) {
+				lc.Register("users", component("users", calls), "db")
+			},
+			initErr: "component \"users\" depends on unknown component \"db\"",
+		},
+		{
+			title: "should fail on circular dependency",
+			register: func(lc *Lifecycle, calls *[]string) {
+				lc.Register("a", component("a", calls), "b").
+					Register("b", component("b", calls), "c").
+					Register("c", component("c", calls), "a")
+			},
+			initErr: "circular dependency: a -> b -> c -> a",
+		},
+		{
+			title: "should fail on duplicate names",
+			register: func(lc *Lifecycle, calls *[]string) {
+				lc.Register("a", component("a", calls)).Register("a", component("a", calls))
+			},
+			initErr: "component \"a\" is registered more than once",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			var calls []string
+			lc := NewLifecycle()
+			tc.register(lc, &calls)
+			if err := lc.Init(); tc.initErr != "" {
+				if err == nil || err.Error() != tc.initErr {
+					t.Errorf("error %v was expected to be %q", err, tc.initErr)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else {
+				err := lc.ShutdownServer(context.Background(), &http.Server{})
+				if (err == nil && tc.stopErr != "") || (err != nil && err.Error() != tc.stopErr) {
+					t.Errorf("error %v was expected to be %q", err, tc.stopErr)
+				}
+			}
+			if strings.Join(calls, ", ") != tc.calls {
+				t.Errorf("calls %q were expected to be %q", strings.Join(calls, ", "), tc.calls)
+			}
+		})
+	}
+}
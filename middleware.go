@@ -38,7 +38,9 @@ func (mw Middleware) Use(middlewares ...Middleware) Middleware {
 	for _, next := range middlewares {
 		mw = func(curr, next Middleware) Middleware {
 			return func(handler http.Handler) http.Handler {
-				return curr(next(handler))
+				// pass the tracer to the current middleware if the chain is being
+				// described (see Describe)
+				return curr(withTracer(next(handler), tracerOf(handler)))
 			}
 		}(mw, next)
 	}
@@ -64,6 +66,8 @@ var blobHandler = func(w http.ResponseWriter, r *http.Request) {}
 // 	- func(http.Handler) http.Handler
 // 	- http.Handler
 // 	- func(w http.ResponseWriter, r *http.Request)
+// 	- HandlerE
+// 	- func(w http.ResponseWriter, r *http.Request) error
 //
 // Keep in mind:
 //
@@ -72,12 +76,47 @@ var blobHandler = func(w http.ResponseWriter, r *http.Request) {}
 // to put something to the context (and do not have any logic after calling "next")
 // there is no sense to build Middleware func around
 //
+// - plain handler does not break the chain even if it sends an error, use
+// ChainStop in order to use plain handlers as guards
+//
+// - error returned by HandlerE breaks the chain, it is sent to the client with
+// RenderError (unless the handler has already written the response), use
+// HandlerE.WithRenderer to send it differently
+//
 // - even if you do not pass any handlers blobHandler will be executed.
+//
+// - Chain panics if any of the arguments has unsupported type (use ChainE in
+// order to handle the error)
 func Chain(handlers ...interface{}) http.Handler {
 	// fake handler in order to wrap last handler call "next"
-	var f http.Handler = http.HandlerFunc(blobHandler)
+	return buildChain(handlers, http.HandlerFunc(blobHandler), nil)
+}
+
+// ChainStop is the same as Chain but it stops calling the next handlers once plain
+// handler (http.Handler or func(w, r)) has sent the status code matching provided
+// predicate (see ErrorOrRedirect), so plain handlers can be used as guards.
+//
+// Example:
+//
+//	mw.ChainStop(mw.ErrorOrRedirect, authorize, handler)
+func ChainStop(stop func(status int) bool, handlers ...interface{}) http.Handler {
+	return buildChain(handlers, http.HandlerFunc(blobHandler), stop)
+}
+
+// ErrorOrRedirect reports whether the status code is an error or redirect (>= 300),
+// it can be used as ChainStop predicate.
+func ErrorOrRedirect(status int) bool {
+	return status >= http.StatusMultipleChoices
+}
+
+// buildChain builds a http.Handler from validated arguments on top of the final
+// handler (see Chain), plain handlers break the chain if the status code they
+// have sent matches stop predicate (if provided).
+func buildChain(handlers []interface{}, final http.Handler, stop func(int) bool) http.Handler {
+	f, tracer := final, tracerOf(final)
 	// apply middleware/handlers from the last to the first one
 	for i := len(handlers) - 1; i >= 0; i-- {
+		f = withTracer(f, tracer)
 		switch t := handlers[i].(type) {
 		// build the handler from classic middleware func
 		case func(http.ResponseWriter, *http.Request, http.Handler):
@@ -97,25 +136,19 @@ func Chain(handlers ...interface{}) http.Handler {
 			f = t(f)
 		case Middleware:
 			f = t(f)
+		// handlers returning an error break the chain if the error is not nil
+		case func(w http.ResponseWriter, r *http.Request) error:
+			f = HandlerE(t).Middleware()(f)
+		case HandlerE:
+			f = t.Middleware()(f)
 		// ordinary functions can also be provided as arguments, in such case they
 		// will be called via adapter http.HandlerFunc
 		case func(w http.ResponseWriter, r *http.Request):
-			f = func(curr, next http.Handler) http.HandlerFunc {
-				return func(w http.ResponseWriter, r *http.Request) {
-					curr.ServeHTTP(w, r)
-					// due to the blobHandler next will never be nil
-					next.ServeHTTP(w, r)
-				}
-			}(http.HandlerFunc(t), f)
+			f = plainHandler(http.HandlerFunc(t), f, stop)
 		// since http.HandlerFunc implements http.Handler interface we can use type
 		// http.Handler for both of them
 		case http.Handler:
-			f = func(curr, next http.Handler) http.HandlerFunc {
-				return func(w http.ResponseWriter, r *http.Request) {
-					curr.ServeHTTP(w, r)
-					next.ServeHTTP(w, r)
-				}
-			}(t, f)
+			f = plainHandler(t, f, stop)
 		default:
 			// everything else is not supported
 			panic(fmt.Sprintf("unsupported argument type \"%T\"", t))
@@ -123,3 +156,23 @@ func Chain(handlers ...interface{}) http.Handler {
 	}
 	return f
 }
+
+// plainHandler calls current handler and the next one unless current handler has
+// sent the status code matching stop predicate.
+func plainHandler(curr, next http.Handler, stop func(int) bool) http.HandlerFunc {
+	if stop == nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			curr.ServeHTTP(w, r)
+			// due to the blobHandler next will never be nil
+			next.ServeHTTP(w, r)
+		}
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := NewResponseWriter(w)
+		curr.ServeHTTP(rw, r)
+		if rw.Written() && stop(rw.Status()) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
@@ -182,3 +182,70 @@ func Test_Chain(t *testing.T) {
 		})
 	}
 }
+
+func Test_ChainStop(t *testing.T) {
+	type testCase struct {
+		title string
+		stop  func(int) bool
+		guard func(w http.ResponseWriter, r *http.Request)
+		code  int
+		out   string
+	}
+
+	guardUnauthorized := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "/unauthorized", http.StatusUnauthorized)
+	}
+
+	cases := []testCase{
+		{
+			title: "should break the chain if plain handler sent an error",
+			stop:  ErrorOrRedirect,
+			guard: guardUnauthorized,
+			code:  http.StatusUnauthorized,
+			out:   "/unauthorized\n",
+		},
+		{
+			title: "should continue the chain if plain handler sent successful response",
+			stop:  ErrorOrRedirect,
+			guard: handlerOne,
+			code:  http.StatusOK,
+			out:   "/first handler/skip the rest",
+		},
+		{
+			title: "should continue the chain if plain handler did not write anything",
+			stop:  ErrorOrRedirect,
+			guard: func(w http.ResponseWriter, r *http.Request) {},
+			code:  http.StatusOK,
+			out:   "/skip the rest",
+		},
+		{
+			title: "should always continue the chain without predicate",
+			stop:  nil,
+			guard: guardUnauthorized,
+			code:  http.StatusUnauthorized,
+			out:   "/unauthorized\n/skip the rest",
+		},
+		{
+			title: "should break the chain according to custom predicate",
+			stop:  func(status int) bool { return status == http.StatusOK },
+			guard: handlerOne,
+			code:  http.StatusOK,
+			out:   "/first handler",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			for _, guard := range []interface{}{tc.guard, http.HandlerFunc(tc.guard)} {
+				w := httptest.NewRecorder()
+				ChainStop(tc.stop, guard, middlewareBreak).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+				if w.Code != tc.code {
+					t.Errorf("status code %d was expected to be %d", w.Code, tc.code)
+				}
+				if w.Body.String() != tc.out {
+					t.Errorf("out %q expected to be %q", w.Body.String(), tc.out)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ChainInfo describes named middleware and named middleware nested into it.
+type ChainInfo struct {
+	Name     string      `json:"name"`
+	Requires []string    `json:"requires,omitempty"`
+	Children []ChainInfo `json:"children,omitempty"`
+}
+
+// chainTracer records named middleware while the chain is being described.
+type chainTracer struct {
+	// stack contains the lists of siblings for each level of nesting (in the
+	// order they have been built, which is reversed comparing to execution order)
+	stack [][]ChainInfo
+	// docs contains the descriptions of documented middleware (see Documented)
+	docs []APIDoc
+}
+
+func newChainTracer() *chainTracer {
+	return &chainTracer{stack: [][]ChainInfo{nil}}
+}
+
+func (ct *chainTracer) enter() {
+	ct.stack = append(ct.stack, nil)
+}
+
+func (ct *chainTracer) exit(info ChainInfo) {
+	info.Children = reverseChain(ct.stack[len(ct.stack)-1])
+	ct.stack = ct.stack[:len(ct.stack)-1]
+	parent := len(ct.stack) - 1
+	ct.stack[parent] = append(ct.stack[parent], info)
+}
+
+// chain returns recorded middleware in the order of execution.
+func (ct *chainTracer) chain() []ChainInfo {
+	return reverseChain(ct.stack[0])
+}
+
+// tracingHandler carries the tracer of the chain that is being described to the
+// middleware wrapping the handler, so the chains built by other goroutines at the
+// same time are not affected.
+type tracingHandler struct {
+	http.Handler
+	tracer *chainTracer
+}
+
+// tracerOf returns the tracer carried by the handler (nil if the chain is not
+// being described).
+func tracerOf(h http.Handler) *chainTracer {
+	if th, ok := h.(*tracingHandler); ok {
+		return th.tracer
+	}
+	return nil
+}
+
+// withTracer makes the tracer available to the middleware that wraps the handler
+// (handler is returned as is if tracer is nil).
+func withTracer(h http.Handler, tracer *chainTracer) http.Handler {
+	if tracer == nil || tracerOf(h) == tracer {
+		return h
+	}
+	return &tracingHandler{Handler: h, tracer: tracer}
+}
+
+// Named gives a name to the middleware, names are used by Describe to show the
+// structure of the chain and by Instrument to measure the time spent in it.
+//
+// Example:
+//
+//	auth := mw.Named("auth", mw.New(
+//	    mw.Named("jwt", mw.JWT(parser, claims)),
+//	    mw.Named("claims", checkClaims),
+//	))
+func Named(name string, mw Middleware) Middleware {
+	return traced(ChainInfo{Name: name}, mw)
+}
+
+// traced records provided info when the chain is being described and measures
+// the time spent in the middleware if request is instrumented (see Instrument).
+func traced(info ChainInfo, mw Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		// the same middleware may be used in the chain more than once
+		id := &timingID{name: info.Name}
+		tracer := tracerOf(next)
+		if tracer == nil {
+			return timed(id, mw, next)
+		}
+		tracer.enter()
+		defer tracer.exit(info)
+		return timed(id, mw, next)
+	}
+}
+
+// Describe returns the list of named middleware (see Named) in the order of their
+// execution. The chain is built with an empty handler in order to find named
+// middleware, the tracer is passed to the middleware with the next handler (so
+// Describe is safe for concurrent use), thus named middleware built by custom
+// middleware with another handler than its next one is not listed.
+func Describe(mw Middleware) []ChainInfo {
+	tracer := newChainTracer()
+	mw(withTracer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), tracer))
+	return tracer.chain()
+}
+
+// reverseChain returns the list of middleware in reversed order.
+func reverseChain(chain []ChainInfo) []ChainInfo {
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
+
+// ControllerChains returns the middleware of the controller registered for each
+// HTTP method (if controller provides the list of methods, see BaseController.Methods)
+// or for all the standard HTTP methods otherwise. The keys of the map have format
+// "METHOD path".
+func ControllerChains(path string, c Controller) map[string]Middleware {
+	methods := []string{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions,
+	}
+	if lister, ok := c.(interface{ Methods() []string }); ok {
+		methods = lister.Methods()
+	}
+	routes := make(map[string]Middleware, len(methods))
+	for _, method := range methods {
+		routes[method+" "+path] = c.Middleware(method)
+	}
+	return routes
+}
+
+// DebugChains returns a handler that prints the named middleware of each route
+// in plain text (nested middleware is indented).
+func DebugChains(routes map[string]Middleware) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		names := make([]string, 0, len(routes))
+		for route := range routes {
+			names = append(names, route)
+		}
+		sort.Strings(names)
+		w.Header().Set(contentTypeHeader, "text/plain; charset=utf-8")
+		for _, route := range names {
+			fmt.Fprintln(w, route)
+			printChain(w, Describe(routes[route]), 1)
+		}
+	})
+}
+
+// printChain writes the chain to provided writer with indentation.
+func printChain(w io.Writer, chain []ChainInfo, depth int) {
+	for _, info := range chain {
+		fmt.Fprintf(w, "%s%s\n", strings.Repeat("  ", depth), info.Name)
+		printChain(w, info.Children, depth+1)
+	}
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func Test_Describe(t *testing.T) {
+	type testCase struct {
+		title string
+		mw    Middleware
+		info  []ChainInfo
+	}
+
+	cases := []testCase{
+		{
+			title: "should return an empty list if chain does not contain named middleware",
+			mw:    New(middlewareOne, middlewareTwo),
+		},
+		{
+			title: "should return named middleware in the order of execution",
+			mw: New(
+				Named("one", middlewareOne),
+				middlewareTwo,
+				Named("three", middlewareThree),
+			),
+			info: []ChainInfo{{Name: "one"}, {Name: "three"}},
+		},
+		{
+			title: "should return nested named middleware",
+			mw: New(
+				Named("outer", New(
+					Named("one", middlewareOne),
+					Named("inner", New(Named("two", middlewareTwo))),
+				)),
+				Named("three", Middleware(middlewareThree).When(PathPrefix("/api"))),
+			),
+			info: []ChainInfo{
+				{Name: "outer", Children: []ChainInfo{
+					{Name: "one"},
+					{Name: "inner", Children: []ChainInfo{{Name: "two"}}},
+				}},
+				{Name: "three"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			if info := Describe(tc.mw); !reflect.DeepEqual(info, tc.info) {
+				t.Errorf("chain info %v was expected to be %v", info, tc.info)
+			}
+		})
+	}
+
+	t.Run("should not record the chains built concurrently", func(t *testing.T) {
+		chain := New(Named("one", middlewareOne), middlewareTwo, Named("two", New(Named("three", middlewareThree))))
+		expected := []ChainInfo{{Name: "one"}, {Name: "two", Children: []ChainInfo{{Name: "three"}}}}
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						New(Named("x", middlewareOne), Named("y", middlewareTwo)).Then(handlerFinal)
+					}
+				}
+			}()
+		}
+		for i := 0; i < 1000; i++ {
+			if info := Describe(chain); !reflect.DeepEqual(info, expected) {
+				t.Errorf("chain info %v was expected to be %v", info, expected)
+				break
+			}
+		}
+		close(stop)
+		wg.Wait()
+	})
+
+	t.Run("named middleware should behave as the original one", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		New(Named("one", middlewareOne)).Then(handlerFinal).ServeHTTP(w, nil)
+		if out := "/mw1 before next/final handler/mw1 after next"; w.Body.String() != out {
+			t.Errorf("the output %q is expected to be %q", w.Body.String(), out)
+		}
+	})
+}
+
+func Test_DebugChains(t *testing.T) {
+	controller := NewBaseController()
+	controller.AddMiddleware(http.MethodGet, Named("auth", New(Named("jwt", middlewareOne))))
+	controller.AddMiddleware(http.MethodPost, Named("auth", middlewareOne), Named("codec", middlewareTwo))
+	routes := ControllerChains("/users", controller)
+	routes["GET /health"] = New()
+
+	w := httptest.NewRecorder()
+	DebugChains(routes).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/chains", nil))
+
+	out := "GET /health\n" +
+		"GET /users\n" +
+		"  auth\n" +
+		"    jwt\n" +
+		"POST /users\n" +
+		"  auth\n" +
+		"  codec\n"
+	if w.Body.String() != out {
+		t.Errorf("the output %q is expected to be %q", w.Body.String(), out)
+	}
+}
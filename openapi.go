@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tiny-go/codec/driver"
+)
+
+// openAPIVersion is the version of OpenAPI specification of generated documents.
+const openAPIVersion = "3.0.3"
+
+// OpenAPIDocument is a minimal OpenAPI 3 document describing mounted routes.
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components *OpenAPIComponents                      `json:"components,omitempty"`
+}
+
+// OpenAPIInfo contains the metadata of the API.
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPIOperation describes a single HTTP method of the path.
+type OpenAPIOperation struct {
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+}
+
+// OpenAPIParameter describes path parameter of the operation.
+type OpenAPIParameter struct {
+	Name     string            `json:"name"`
+	In       string            `json:"in"`
+	Required bool              `json:"required"`
+	Schema   map[string]string `json:"schema"`
+}
+
+// OpenAPIRequestBody lists the content types accepted by the operation.
+type OpenAPIRequestBody struct {
+	Content map[string]OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes the response of the operation.
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType describes the content of request/response body.
+type OpenAPIMediaType struct{}
+
+// OpenAPIComponents contains the security schemes used by the operations.
+type OpenAPIComponents struct {
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme describes the authentication performed by the middleware.
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+// APIDoc describes the middleware in OpenAPI document (see Documented).
+type APIDoc struct {
+	// SecurityName is the name of security scheme required by the middleware (if any).
+	SecurityName string
+	// Security is the security scheme of the middleware.
+	Security SecurityScheme
+	// Responses lists the status codes the middleware may respond with.
+	Responses []int
+	// results contains the status codes of successful responses of the handler
+	// (see Router.Resource) and whether they have a body.
+	results map[int]bool
+}
+
+// Documented attaches the description to the middleware, it is used by NewOpenAPI
+// to add security requirements and responses to the operations. The built-in
+// middleware (JWT, ContextHandler, RequestLimiter, BodyLimit, Validate) describes
+// itself.
+//
+// Example:
+//
+//	mw.Documented(mw.APIDoc{
+//	    SecurityName: "APIKey",
+//	    Security:     mw.SecurityScheme{Type: "apiKey", In: "header", Name: "X-API-Key"},
+//	    Responses:    []int{http.StatusUnauthorized},
+//	}, apiKey)
+func Documented(doc APIDoc, mw Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		document(next, doc)
+		return mw(next)
+	}
+}
+
+// document records the description of the middleware wrapping the handler if
+// the chain is being described.
+func document(next http.Handler, doc APIDoc) {
+	if tracer := tracerOf(next); tracer != nil {
+		tracer.docs = append(tracer.docs, doc)
+	}
+}
+
+// jwtDoc describes JWT middleware.
+var jwtDoc = APIDoc{
+	SecurityName: "JWT",
+	Security:     SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+	Responses:    []int{http.StatusUnauthorized},
+}
+
+// pathParamRegexp matches path parameters (e.g. "{id}" of Resource item routes).
+var pathParamRegexp = regexp.MustCompile(`{([^{}/]+)}`)
+
+// NewOpenAPI generates OpenAPI document from the routes (see Router.Routes and
+// ControllerChains). Security requirements and additional responses of each
+// operation are inferred from the middleware of the route (see Documented), the
+// responses of Router.Resource routes are taken from the interfaces of the
+// controller (e.g. 201 for Creator, 204 for Deleter), other routes are documented
+// with 200 response.
+//
+// Request and response content types are taken from provided codec registry if
+// it can list them (driver.DummyRegistry or a registry with MimeTypes method).
+func NewOpenAPI(info OpenAPIInfo, codecs Codecs, routes map[string]Middleware) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info:    info,
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+	}
+	content := make(map[string]OpenAPIMediaType)
+	for _, mimeType := range mimeTypes(codecs) {
+		content[mimeType] = OpenAPIMediaType{}
+	}
+	if len(content) == 0 {
+		content = nil
+	}
+	for key, mw := range routes {
+		parts := strings.SplitN(key, " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		method, path := parts[0], parts[1]
+		op := &OpenAPIOperation{Responses: make(map[string]OpenAPIResponse)}
+		for _, match := range pathParamRegexp.FindAllStringSubmatch(path, -1) {
+			op.Parameters = append(op.Parameters, OpenAPIParameter{
+				Name: match[1], In: "path", Required: true, Schema: map[string]string{"type": "string"},
+			})
+		}
+		switch method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+			if content != nil {
+				op.RequestBody = &OpenAPIRequestBody{Content: content}
+			}
+		}
+		tracer := newChainTracer()
+		mw(withTracer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), tracer))
+		requirement := make(map[string][]string)
+		results := map[int]bool{http.StatusOK: true}
+		for _, apiDoc := range tracer.docs {
+			if apiDoc.SecurityName != "" {
+				requirement[apiDoc.SecurityName] = []string{}
+				if doc.Components == nil {
+					doc.Components = &OpenAPIComponents{SecuritySchemes: make(map[string]SecurityScheme)}
+				}
+				doc.Components.SecuritySchemes[apiDoc.SecurityName] = apiDoc.Security
+			}
+			for _, code := range apiDoc.Responses {
+				op.Responses[strconv.Itoa(code)] = OpenAPIResponse{Description: http.StatusText(code)}
+			}
+			if apiDoc.results != nil {
+				results = apiDoc.results
+			}
+		}
+		for code, withBody := range results {
+			response := OpenAPIResponse{Description: http.StatusText(code)}
+			if withBody {
+				response.Content = content
+			}
+			op.Responses[strconv.Itoa(code)] = response
+		}
+		if len(requirement) > 0 {
+			op.Security = []map[string][]string{requirement}
+		}
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*OpenAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(method)] = op
+	}
+	return doc
+}
+
+// OpenAPI returns a handler that serves OpenAPI document (in JSON format) generated
+// from the routes, the document is generated once (see NewOpenAPI).
+func OpenAPI(info OpenAPIInfo, codecs Codecs, routes map[string]Middleware) http.Handler {
+	data, err := json.Marshal(NewOpenAPI(info, codecs, routes))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(contentTypeHeader, "application/json")
+		w.Write(data)
+	})
+}
+
+// mimeTypes returns the list of mime types supported by codec registry (if the
+// registry is able to provide it).
+func mimeTypes(codecs Codecs) []string {
+	switch registry := codecs.(type) {
+	case driver.DummyRegistry:
+		list := make([]string, 0, len(registry))
+		for _, c := range registry {
+			list = append(list, c.MimeType())
+		}
+		return list
+	case interface{ MimeTypes() []string }:
+		return registry.MimeTypes()
+	default:
+		return nil
+	}
+}
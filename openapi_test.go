@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/tiny-go/codec/driver"
+	codecJSON "github.com/tiny-go/codec/driver/json"
+)
+
+func Test_OpenAPI(t *testing.T) {
+	controller := &usersController{BaseController: NewBaseController(), users: map[string]resourceUser{}}
+	controller.AddMiddleware(AnyMethod, ContextHandler)
+	controller.AddMiddleware(http.MethodPost, Named("auth", New(
+		JWT(nil, nil),
+		RequestLimiter(nil, 10).When(Methods(http.MethodPost)),
+	)))
+	codecs := driver.DummyRegistry{&codecJSON.JSON{}}
+	router := NewRouter().Resource("/users", controller, codecs)
+
+	w := httptest.NewRecorder()
+	OpenAPI(OpenAPIInfo{Title: "users", Version: "1.0"}, codecs, router.Routes()).
+		ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if contentType := w.Header().Get(contentTypeHeader); contentType != "application/json" {
+		t.Errorf("content type %q was expected to be %q", contentType, "application/json")
+	}
+	var doc OpenAPIDocument
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("cannot decode the document: %v", err)
+	}
+
+	if doc.OpenAPI != "3.0.3" || doc.Info.Title != "users" || doc.Info.Version != "1.0" {
+		t.Errorf("unexpected document header: %q %v", doc.OpenAPI, doc.Info)
+	}
+	content := map[string]OpenAPIMediaType{"application/json": {}}
+
+	t.Run("should list the paths and the methods", func(t *testing.T) {
+		methods := map[string][]string{}
+		for path, ops := range doc.Paths {
+			for _, method := range []string{"get", "post", "put", "patch", "delete"} {
+				if _, ok := ops[method]; ok {
+					methods[path] = append(methods[path], method)
+				}
+			}
+		}
+		expected := map[string][]string{"/users": {"get", "post"}, "/users/{id}": {"get", "put", "delete"}}
+		if !reflect.DeepEqual(methods, expected) {
+			t.Errorf("methods %v were expected to be %v", methods, expected)
+		}
+	})
+
+	t.Run("should infer security and responses from the middleware and the controller", func(t *testing.T) {
+		op := doc.Paths["/users"]["post"]
+		expected := &OpenAPIOperation{
+			RequestBody: &OpenAPIRequestBody{Content: content},
+			Responses: map[string]OpenAPIResponse{
+				"201": {Description: "Created", Content: content},
+				"401": {Description: "Unauthorized"},
+				"408": {Description: "Request Timeout"},
+				"429": {Description: "Too Many Requests"},
+			},
+			Security: []map[string][]string{{"JWT": {}}},
+		}
+		if !reflect.DeepEqual(op, expected) {
+			t.Errorf("operation %+v was expected to be %+v", op, expected)
+		}
+		schemes := map[string]SecurityScheme{"JWT": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"}}
+		if doc.Components == nil || !reflect.DeepEqual(doc.Components.SecuritySchemes, schemes) {
+			t.Errorf("components %v were expected to contain %v", doc.Components, schemes)
+		}
+	})
+
+	t.Run("should describe path parameters", func(t *testing.T) {
+		op := doc.Paths["/users/{id}"]["delete"]
+		expected := &OpenAPIOperation{
+			Parameters: []OpenAPIParameter{{Name: "id", In: "path", Required: true, Schema: map[string]string{"type": "string"}}},
+			Responses: map[string]OpenAPIResponse{
+				"204": {Description: "No Content"},
+				"404": {Description: "Not Found"},
+				"408": {Description: "Request Timeout"},
+			},
+		}
+		if !reflect.DeepEqual(op, expected) {
+			t.Errorf("operation %+v was expected to be %+v", op, expected)
+		}
+	})
+
+	t.Run("should describe custom middleware of mounted handlers", func(t *testing.T) {
+		scheme := SecurityScheme{Type: "apiKey", In: "header", Name: "X-API-Key"}
+		doc := NewOpenAPI(OpenAPIInfo{}, nil, map[string]Middleware{
+			"GET /health": New(Documented(APIDoc{SecurityName: "APIKey", Security: scheme, Responses: []int{http.StatusUnauthorized}}, New())),
+		})
+		expected := &OpenAPIOperation{
+			Responses: map[string]OpenAPIResponse{
+				"200": {Description: "OK"},
+				"401": {Description: "Unauthorized"},
+			},
+			Security: []map[string][]string{{"APIKey": {}}},
+		}
+		if op := doc.Paths["/health"]["get"]; !reflect.DeepEqual(op, expected) {
+			t.Errorf("operation %+v was expected to be %+v", op, expected)
+		}
+	})
+}
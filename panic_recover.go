@@ -45,7 +45,14 @@ func PanicRecover(onPanic OnPanic) Middleware {
 		return http.HandlerFunc(
 			func(w http.ResponseWriter, r *http.Request) {
 				// recover from panic and call the panic handler
-				defer func() { onPanic(w, recover()) }()
+				defer func() {
+					p := recover()
+					if p != nil {
+						// the request is available to the handler (see ErrorRequest)
+						w = &errorWriter{ResponseWriter: w, r: r}
+					}
+					onPanic(w, p)
+				}()
 				// call next middleware
 				next.ServeHTTP(w, r)
 			},
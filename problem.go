@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+
+	"github.com/tiny-go/codec"
+	"github.com/tiny-go/errors"
+)
+
+const (
+	// MimeTypeProblemJSON is a mime type of RFC 7807 problem details in JSON format.
+	MimeTypeProblemJSON = "application/problem+json"
+	// MimeTypeProblemXML is a mime type of RFC 7807 problem details in XML format.
+	MimeTypeProblemXML = "application/problem+xml"
+)
+
+// Problem represents RFC 7807 problem details.
+type Problem struct {
+	XMLName xml.Name `json:"-" xml:"urn:ietf:rfc:7807 problem"`
+	// Type is a URI reference that identifies the problem type.
+	Type string `json:"type,omitempty" xml:"type,omitempty"`
+	// Title is a short, human-readable summary of the problem type.
+	Title string `json:"title,omitempty" xml:"title,omitempty"`
+	// Status is the HTTP status code.
+	Status int `json:"status,omitempty" xml:"status,omitempty"`
+	// Detail is a human-readable explanation specific to this occurrence of the problem.
+	Detail string `json:"detail,omitempty" xml:"detail,omitempty"`
+	// Instance identifies the specific occurrence of the problem (request ID).
+	Instance string `json:"instance,omitempty" xml:"instance,omitempty"`
+	// Violations is an extension member listing invalid fields (see Validate).
+	Violations []FieldViolation `json:"violations,omitempty" xml:"violation,omitempty"`
+}
+
+// NewProblem creates problem details from the error message and status code,
+// request ID (see RequestID middleware) is used as a problem instance.
+func NewProblem(r *http.Request, message string, code int) *Problem {
+	problem := &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(code),
+		Status: code,
+		Detail: message,
+	}
+	if problem.Title == "" {
+		// non-standard status codes (like StatusNoResponse)
+		problem.Title, problem.Detail = message, ""
+	}
+	if r != nil {
+		if requestID := RequestIDFromContext(r.Context()); requestID != "" {
+			problem.Instance = requestID
+		}
+	}
+	return problem
+}
+
+// Error returns the detail of the problem (to satisfy error interface).
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+// Code returns HTTP status code (to satisfy errors.Error interface).
+func (p *Problem) Code() int { return p.Status }
+
+// errorWriter makes the request of the middleware (and the error it sends)
+// available to error handlers, see ErrorRequest.
+type errorWriter struct {
+	http.ResponseWriter
+	r   *http.Request
+	err error
+}
+
+// sendError sends the error of the middleware with provided errors.HandlerFunc.
+func sendError(fn errors.HandlerFunc, w http.ResponseWriter, r *http.Request, message string, code int) {
+	fn(&errorWriter{ResponseWriter: w, r: r}, message, code)
+}
+
+// ErrorRequest returns the request which caused the error sent by the middleware
+// (nil if the error handler was not called by the middleware of the package), it
+// can be used by custom errors.HandlerFunc or OnPanic handlers.
+func ErrorRequest(w http.ResponseWriter) *http.Request {
+	if ew, ok := w.(*errorWriter); ok {
+		return ew.r
+	}
+	return nil
+}
+
+// ProblemError sends RFC 7807 problem details, it can be provided to the middleware
+// constructors as errors.HandlerFunc instead of http.Error. The problem is encoded
+// with the negotiated response codec and request ID is used as its instance (see
+// SendProblem), the violations are listed if validation error is sent by Validate.
+func ProblemError(w http.ResponseWriter, message string, code int) {
+	r := ErrorRequest(w)
+	problem := NewProblem(r, message, code)
+	if ew, ok := w.(*errorWriter); ok {
+		if verr, ok := ew.err.(*ValidationError); ok {
+			problem.Violations = verr.Violations
+		}
+	}
+	SendProblem(w, r, problem)
+}
+
+// PanicProblem is OnPanic handler that sends RFC 7807 problem details in the same
+// way as errors.Send sends plain text errors (see PanicRecover).
+func PanicProblem(w http.ResponseWriter, p interface{}) {
+	switch e := p.(type) {
+	case nil:
+		// not a panic
+	case errors.Error:
+		ProblemError(w, e.Error(), e.Code())
+	case error:
+		// error message is not exposed
+		ProblemError(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	default:
+		ProblemError(w, fmt.Sprint(p), http.StatusInternalServerError)
+	}
+}
+
+// SendProblem encodes and sends provided problem details to the client with
+// the negotiated response codec (see Codec middleware) or JSON by default.
+func SendProblem(w http.ResponseWriter, r *http.Request, problem *Problem) {
+	var resCodec codec.Codec
+	if r != nil {
+		resCodec = ResponseCodecFromContext(r.Context())
+	}
+	var (
+		buf bytes.Buffer
+		err error
+	)
+	switch {
+	// streaming codecs are not suitable for a single document
+	case resCodec == nil, resCodec.MimeType() == "application/json", lookupStreamCodec(resCodec.MimeType()) != nil:
+		w.Header().Set(contentTypeHeader, MimeTypeProblemJSON)
+		err = json.NewEncoder(&buf).Encode(problem)
+	case resCodec.MimeType() == "application/xml":
+		w.Header().Set(contentTypeHeader, MimeTypeProblemXML)
+		err = resCodec.Encoder(&buf).Encode(problem)
+	default:
+		w.Header().Set(contentTypeHeader, resCodec.MimeType())
+		err = resCodec.Encoder(&buf).Encode(problem)
+	}
+	if err != nil {
+		// codec is not able to encode the problem - fall back to plain text
+		http.Error(w, problem.Error(), problem.Status)
+		return
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tiny-go/codec/driver"
+	"github.com/tiny-go/codec/driver/json"
+	"github.com/tiny-go/codec/driver/xml"
+	"github.com/tiny-go/errors"
+)
+
+func Test_ProblemError(t *testing.T) {
+	t.Run("Given JWT middleware configured to send problem details", func(t *testing.T) {
+		r, _ := http.NewRequest(http.MethodGet, "", nil)
+		w := httptest.NewRecorder()
+		NewJWT(ProblemError, nil, nil)(nil).ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("status code %d was expected to be %d", w.Code, http.StatusUnauthorized)
+		}
+		if ctype := w.Header().Get(contentTypeHeader); ctype != MimeTypeProblemJSON {
+			t.Errorf("content type %q was expected to be %q", ctype, MimeTypeProblemJSON)
+		}
+		body := "{\"type\":\"about:blank\",\"title\":\"Unauthorized\",\"status\":401,\"detail\":\"no JSON web token in request\"}\n"
+		if w.Body.String() != body {
+			t.Errorf("response body %q was expected to be %q", w.Body.String(), body)
+		}
+	})
+
+	t.Run("should use request ID and the negotiated codec of the request", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "", "age": 18, "email": "a@b", "address": {"city": "Kyiv"}}`))
+		r.Header.Set(requestIDHeader, "42")
+		r.Header.Set(contentTypeHeader, "application/json")
+		r.Header.Set(acceptHeader, "application/xml")
+		w := httptest.NewRecorder()
+		New(RequestID, Codec(ProblemError, driver.DummyRegistry{&json.JSON{}, &xml.XML{}})).
+			Use(Validate(ProblemError, func() interface{} { return new(validatedUser) })).
+			Then(handlerFinal).
+			ServeHTTP(w, r)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("status code %d was expected to be %d", w.Code, http.StatusUnprocessableEntity)
+		}
+		if ctype := w.Header().Get(contentTypeHeader); ctype != MimeTypeProblemXML {
+			t.Errorf("content type %q was expected to be %q", ctype, MimeTypeProblemXML)
+		}
+		body := "<problem xmlns=\"urn:ietf:rfc:7807\"><type>about:blank</type><title>Unprocessable Entity</title>" +
+			"<status>422</status><detail>name: is required</detail><instance>42</instance>" +
+			"<violation field=\"name\" rule=\"required\">is required</violation></problem>"
+		if w.Body.String() != body {
+			t.Errorf("response body %q was expected to be %q", w.Body.String(), body)
+		}
+	})
+
+	t.Run("should not find the request of direct call", func(t *testing.T) {
+		if r := ErrorRequest(httptest.NewRecorder()); r != nil {
+			t.Errorf("unexpected request: %v", r)
+		}
+	})
+
+	t.Run("middleware without error handler should send plain text errors", func(t *testing.T) {
+		r, _ := http.NewRequest(http.MethodGet, "", nil)
+		r.Header.Set(contentTypeHeader, "unknown")
+		w := httptest.NewRecorder()
+		Codec(nil, driver.DummyRegistry{&json.JSON{}})(nil).ServeHTTP(w, r)
+		if body := "unsupported request codec: \"unknown\"\n"; w.Body.String() != body {
+			t.Errorf("response body %q was expected to be %q", w.Body.String(), body)
+		}
+	})
+}
+
+func Test_SendProblem(t *testing.T) {
+	type testCase struct {
+		title   string
+		request func() *http.Request
+		code    int
+		ctype   string
+		body    string
+	}
+
+	cases := []testCase{
+		{
+			title: "should send problem details in JSON format by default",
+			request: func() *http.Request {
+				r, _ := http.NewRequest(http.MethodGet, "", nil)
+				return r
+			},
+			code:  http.StatusUnauthorized,
+			ctype: MimeTypeProblemJSON,
+			body:  "{\"type\":\"about:blank\",\"title\":\"Unauthorized\",\"status\":401,\"detail\":\"no JSON web token in request\"}\n",
+		},
+		{
+			title: "should use request ID as an instance of the problem",
+			request: func() *http.Request {
+				r, _ := http.NewRequest(http.MethodGet, "", nil)
+				return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, "42"))
+			},
+			code:  http.StatusUnauthorized,
+			ctype: MimeTypeProblemJSON,
+			body:  "{\"type\":\"about:blank\",\"title\":\"Unauthorized\",\"status\":401,\"detail\":\"no JSON web token in request\",\"instance\":\"42\"}\n",
+		},
+		{
+			title: "should encode problem details with the negotiated response codec",
+			request: func() *http.Request {
+				r, _ := http.NewRequest(http.MethodGet, "", nil)
+				return r.WithContext(context.WithValue(r.Context(), codecKey{"res"}, &xml.XML{}))
+			},
+			code:  http.StatusUnauthorized,
+			ctype: MimeTypeProblemXML,
+			body: "<problem xmlns=\"urn:ietf:rfc:7807\"><type>about:blank</type><title>Unauthorized</title>" +
+				"<status>401</status><detail>no JSON web token in request</detail></problem>",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := tc.request()
+			SendProblem(w, r, NewProblem(r, "no JSON web token in request", http.StatusUnauthorized))
+			if w.Code != tc.code {
+				t.Errorf("status code %d was expected to be %d", w.Code, tc.code)
+			}
+			if ctype := w.Header().Get(contentTypeHeader); ctype != tc.ctype {
+				t.Errorf("content type %q was expected to be %q", ctype, tc.ctype)
+			}
+			if w.Body.String() != tc.body {
+				t.Errorf("response body %q was expected to be %q", w.Body.String(), tc.body)
+			}
+		})
+	}
+}
+
+func Test_PanicProblem(t *testing.T) {
+	type testCase struct {
+		title string
+		panic interface{}
+		body  string
+	}
+
+	cases := []testCase{
+		{
+			title: "should send the status code of the error",
+			panic: errors.NewStatusError(http.StatusConflict, stderrors.New("already exists")),
+			body:  "{\"type\":\"about:blank\",\"title\":\"Conflict\",\"status\":409,\"detail\":\"already exists\",\"instance\":\"42\"}\n",
+		},
+		{
+			title: "should not expose the message of unknown errors",
+			panic: stderrors.New("secret"),
+			body: "{\"type\":\"about:blank\",\"title\":\"Internal Server Error\",\"status\":500," +
+				"\"detail\":\"Internal Server Error\",\"instance\":\"42\"}\n",
+		},
+		{
+			title: "should send other values as 500",
+			panic: "something went wrong",
+			body: "{\"type\":\"about:blank\",\"title\":\"Internal Server Error\",\"status\":500," +
+				"\"detail\":\"something went wrong\",\"instance\":\"42\"}\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set(requestIDHeader, "42")
+			w := httptest.NewRecorder()
+			New(RequestID, PanicRecover(PanicProblem)).
+				Then(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic(tc.panic) })).
+				ServeHTTP(w, r)
+			if ctype := w.Header().Get(contentTypeHeader); ctype != MimeTypeProblemJSON {
+				t.Errorf("content type %q was expected to be %q", ctype, MimeTypeProblemJSON)
+			}
+			if w.Body.String() != tc.body {
+				t.Errorf("response body %q was expected to be %q", w.Body.String(), tc.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/tiny-go/errors"
+)
+
+const contentEncodingHeader = "Content-Encoding"
+
+// minDecompressedSize is the amount of decompressed data that is allowed regardless
+// of decompression ratio (small bodies may be compressed very well).
+const minDecompressedSize = 64 << 10
+
+// ErrDecompressionRatio is returned by decompressed request body reader when the
+// ratio of decompressed/compressed data exceeds the limit (protection from zip
+// bombs), it has HTTP status code 413 (Request Entity Too Large).
+var ErrDecompressionRatio = errors.NewStatusError(http.StatusRequestEntityTooLarge, stderrors.New("request body decompression ratio exceeded"))
+
+// RequestDecompress is a middleware that transparently decompresses request body
+// encoded with gzip or deflate (see Content-Encoding header). Reading fails with
+// ErrDecompressionRatio as soon as the amount of decompressed data exceeds maxRatio
+// times the amount of compressed data consumed by decompressor (the ratio is not
+// checked until 64 KB of data is decompressed). Use it along with BodyLimit (which should
+// be placed before) to limit the size of compressed body. Errors are sent with
+// provided errors.HandlerFunc (http.Error by default).
+func RequestDecompress(fn errors.HandlerFunc, maxRatio int64) Middleware {
+	if fn == nil {
+		fn = http.Error
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := strings.ToLower(strings.TrimSpace(r.Header.Get(contentEncodingHeader)))
+			if encoding == "" || encoding == "identity" || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+			compressed := &countingReader{Reader: bufio.NewReader(r.Body)}
+			var (
+				reader io.ReadCloser
+				err    error
+			)
+			switch encoding {
+			case "gzip", "x-gzip":
+				reader, err = gzip.NewReader(compressed)
+			case "deflate":
+				reader, err = zlib.NewReader(compressed)
+			default:
+				sendError(fn, w, r, fmt.Sprintf("unsupported content encoding: %q", encoding), http.StatusUnsupportedMediaType)
+				return
+			}
+			if err != nil {
+				sendError(fn, w, r, fmt.Sprintf("invalid %s request body: %s", encoding, err), http.StatusBadRequest)
+				return
+			}
+			r.Body = &decompressedBody{reader: reader, body: r.Body, compressed: compressed, maxRatio: maxRatio}
+			// the length of decompressed body is unknown
+			r.Header.Del(contentEncodingHeader)
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// countingReader counts compressed bytes consumed by decompressor, it implements
+// io.ByteReader, so decompressor does not read ahead with its own buffer.
+type countingReader struct {
+	*bufio.Reader
+	count int64
+}
+
+// Read reads from the underlying reader counting the bytes.
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.Reader.Read(p)
+	cr.count += int64(n)
+	return n, err
+}
+
+// ReadByte reads a single byte from the underlying reader counting it.
+func (cr *countingReader) ReadByte() (byte, error) {
+	b, err := cr.Reader.ReadByte()
+	if err == nil {
+		cr.count++
+	}
+	return b, err
+}
+
+// decompressedBody checks decompression ratio while reading.
+type decompressedBody struct {
+	reader       io.ReadCloser
+	body         io.ReadCloser
+	compressed   *countingReader
+	decompressed int64
+	maxRatio     int64
+}
+
+// Read reads decompressed data.
+func (db *decompressedBody) Read(p []byte) (int, error) {
+	n, err := db.reader.Read(p)
+	db.decompressed += int64(n)
+	if db.maxRatio > 0 && db.decompressed > minDecompressedSize && db.decompressed > db.maxRatio*db.compressed.count {
+		return n, ErrDecompressionRatio
+	}
+	return n, err
+}
+
+// Close closes both decompressing reader and original request body.
+func (db *decompressedBody) Close() error {
+	db.reader.Close()
+	return db.body.Close()
+}
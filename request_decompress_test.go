@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tiny-go/errors"
+)
+
+func Test_RequestDecompress(t *testing.T) {
+	compress := func(encoding, data string) io.Reader {
+		var (
+			buf bytes.Buffer
+			w   io.WriteCloser
+		)
+		switch encoding {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		}
+		w.Write([]byte(data))
+		w.Close()
+		return &buf
+	}
+
+	type testCase struct {
+		title    string
+		encoding string
+		body     io.Reader
+		code     int
+		out      string
+	}
+
+	cases := []testCase{
+		{
+			title: "should pass the body without encoding as is",
+			body:  strings.NewReader("plain"),
+			code:  http.StatusOK,
+			out:   "plain",
+		},
+		{
+			title:    "should decompress gzip encoded body",
+			encoding: "gzip",
+			body:     compress("gzip", "compressed with gzip"),
+			code:     http.StatusOK,
+			out:      "compressed with gzip",
+		},
+		{
+			title:    "should decompress deflate encoded body",
+			encoding: "deflate",
+			body:     compress("deflate", "compressed with deflate"),
+			code:     http.StatusOK,
+			out:      "compressed with deflate",
+		},
+		{
+			title:    "should reject unsupported encoding",
+			encoding: "br",
+			body:     strings.NewReader("unknown"),
+			code:     http.StatusUnsupportedMediaType,
+			out:      "unsupported content encoding: \"br\"\n",
+		},
+		{
+			title:    "should reject invalid compressed data",
+			encoding: "gzip",
+			body:     strings.NewReader("not compressed"),
+			code:     http.StatusBadRequest,
+			out:      "invalid gzip request body: gzip: invalid header\n",
+		},
+		{
+			title:    "should not check the ratio of small bodies",
+			encoding: "gzip",
+			body:     compress("gzip", strings.Repeat("0", 32<<10)),
+			code:     http.StatusOK,
+			out:      strings.Repeat("0", 32<<10),
+		},
+		{
+			title:    "should stop decompression if ratio is exceeded",
+			encoding: "gzip",
+			body:     compress("gzip", strings.Repeat("0", 1<<20)),
+			code:     http.StatusRequestEntityTooLarge,
+			out:      "request body decompression ratio exceeded\n",
+		},
+	}
+
+	handler := RequestDecompress(nil, 100)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(contentEncodingHeader) != "" {
+			t.Error("content encoding header should be removed")
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			errors.Send(w, err)
+			return
+		}
+		w.Write(data)
+	}))
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodPost, "", tc.body)
+			r.Header.Set(contentEncodingHeader, tc.encoding)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tc.code {
+				t.Errorf("status code %d was expected to be %d", w.Code, tc.code)
+			}
+			if w.Body.String() != tc.out {
+				t.Errorf("response body %q was expected to be %q", w.Body.String(), tc.out)
+			}
+		})
+	}
+}
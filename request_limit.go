@@ -14,13 +14,14 @@ func RequestLimiter(fn errors.HandlerFunc, maxConcurrentRequests int) Middleware
 	}
 	limitChan := make(chan struct{}, maxConcurrentRequests)
 	return func(next http.Handler) http.Handler {
+		document(next, APIDoc{Responses: []int{http.StatusTooManyRequests}})
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			select {
 			case limitChan <- struct{}{}:
 				defer func() { <-limitChan }()
 				next.ServeHTTP(w, r)
 			default:
-				fn(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				sendError(fn, w, r, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
 			}
 		})
 	}
@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tiny-go/codec"
+	"github.com/tiny-go/errors"
+)
+
+// Lister is implemented by resource controllers that list the collection
+// (GET /path).
+type Lister interface {
+	List(r *http.Request) (interface{}, error)
+}
+
+// Creator is implemented by resource controllers that add items to the collection
+// (POST /path), decode func decodes request body with the request codec.
+type Creator interface {
+	Create(r *http.Request, decode func(interface{}) error) (interface{}, error)
+}
+
+// Getter is implemented by resource controllers that return a single item
+// (GET /path/{id}).
+type Getter interface {
+	Get(r *http.Request, id string) (interface{}, error)
+}
+
+// Updater is implemented by resource controllers that replace the item
+// (PUT /path/{id}).
+type Updater interface {
+	Update(r *http.Request, id string, decode func(interface{}) error) (interface{}, error)
+}
+
+// Patcher is implemented by resource controllers that partially update the item
+// (PATCH /path/{id}).
+type Patcher interface {
+	Patch(r *http.Request, id string, decode func(interface{}) error) (interface{}, error)
+}
+
+// Deleter is implemented by resource controllers that delete the item
+// (DELETE /path/{id}).
+type Deleter interface {
+	Delete(r *http.Request, id string) error
+}
+
+// Resource mounts collection (path) and item (path/{id}) routes of the resource
+// controller according to the interfaces it implements (see Lister, Creator,
+// Getter, Updater, Patcher and Deleter). The middleware of the controller is
+// applied per HTTP method (see ServeController). Request and response bodies are
+// encoded with the codecs negotiated by Codec middleware, or the codecs are looked
+// up in provided registry if Codec middleware was not applied. The errors returned
+// by controller are sent with RenderError (e.g. errors.NotFound is sent with status
+// 404) unless another renderer was provided with WithErrorRenderer.
+func (rt *Router) Resource(path string, c Controller, codecs Codecs) *Router {
+	path = strings.TrimSuffix(path, "/")
+	res := &resource{prefix: path + "/", codecs: codecs}
+
+	collection := make(map[string]HandlerE)
+	if lister, ok := c.(Lister); ok {
+		collection[http.MethodGet] = HandlerE(func(w http.ResponseWriter, r *http.Request) error {
+			data, err := lister.List(r)
+			return res.send(w, r, http.StatusOK, data, err)
+		})
+	}
+	if creator, ok := c.(Creator); ok {
+		collection[http.MethodPost] = HandlerE(func(w http.ResponseWriter, r *http.Request) error {
+			data, err := creator.Create(r, res.decoder(r))
+			return res.send(w, r, http.StatusCreated, data, err)
+		})
+	}
+
+	item := make(map[string]HandlerE)
+	if getter, ok := c.(Getter); ok {
+		item[http.MethodGet] = res.item(func(w http.ResponseWriter, r *http.Request, id string) error {
+			data, err := getter.Get(r, id)
+			return res.send(w, r, http.StatusOK, data, err)
+		})
+	}
+	if updater, ok := c.(Updater); ok {
+		item[http.MethodPut] = res.item(func(w http.ResponseWriter, r *http.Request, id string) error {
+			data, err := updater.Update(r, id, res.decoder(r))
+			return res.send(w, r, http.StatusOK, data, err)
+		})
+	}
+	if patcher, ok := c.(Patcher); ok {
+		item[http.MethodPatch] = res.item(func(w http.ResponseWriter, r *http.Request, id string) error {
+			data, err := patcher.Patch(r, id, res.decoder(r))
+			return res.send(w, r, http.StatusOK, data, err)
+		})
+	}
+	if deleter, ok := c.(Deleter); ok {
+		item[http.MethodDelete] = res.item(func(w http.ResponseWriter, r *http.Request, id string) error {
+			return res.send(w, r, http.StatusNoContent, nil, deleter.Delete(r, id))
+		})
+	}
+
+	rt.mount(path, path, c, rt.handlers(collection), resourceDocs(collection))
+	return rt.mount(res.prefix, res.prefix+"{id}", c, rt.handlers(item), resourceDocs(item, http.StatusNotFound))
+}
+
+// resourceResults contains the successful responses of resource handlers by HTTP
+// method (status code and whether the response has a body).
+var resourceResults = map[string]map[int]bool{
+	http.MethodGet:    {http.StatusOK: true},
+	http.MethodPost:   {http.StatusCreated: true},
+	http.MethodPut:    {http.StatusOK: true, http.StatusNoContent: false},
+	http.MethodPatch:  {http.StatusOK: true, http.StatusNoContent: false},
+	http.MethodDelete: {http.StatusNoContent: false},
+}
+
+// resourceDocs describes the responses of resource handlers for OpenAPI document.
+func resourceDocs(handlers map[string]HandlerE, responses ...int) map[string]APIDoc {
+	docs := make(map[string]APIDoc, len(handlers))
+	for method := range handlers {
+		docs[method] = APIDoc{Responses: responses, results: resourceResults[method]}
+	}
+	return docs
+}
+
+// handlers converts resource handlers to http.Handler rendering their errors with
+// the renderer of the router.
+func (rt *Router) handlers(handlers map[string]HandlerE) map[string]http.Handler {
+	converted := make(map[string]http.Handler, len(handlers))
+	for method, handler := range handlers {
+		converted[method] = handler.WithRenderer(rt.render)
+	}
+	return converted
+}
+
+// resource contains the common logic of resource handlers.
+type resource struct {
+	prefix string
+	codecs Codecs
+}
+
+// item builds the handler of item route passing the ID of the item from URL path,
+// it returns 404 (Not Found) if the ID is empty or contains nested path.
+func (res *resource) item(handler func(w http.ResponseWriter, r *http.Request, id string) error) HandlerE {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		id := strings.TrimPrefix(r.URL.Path, res.prefix)
+		if id == "" || strings.Contains(id, "/") {
+			return errors.NotFound(http.StatusText(http.StatusNotFound))
+		}
+		return handler(w, r, id)
+	}
+}
+
+// decoder returns a func that decodes request body with the request codec.
+func (res *resource) decoder(r *http.Request) func(interface{}) error {
+	return func(v interface{}) error {
+		reqCodec := RequestCodecFromContext(r.Context())
+		if reqCodec == nil && res.codecs != nil {
+			reqCodec = res.codecs.Lookup(r.Header.Get(contentTypeHeader))
+		}
+		if reqCodec == nil {
+			return errors.NewStatusError(http.StatusUnsupportedMediaType,
+				fmt.Errorf("unsupported request codec: %q", r.Header.Get(contentTypeHeader)))
+		}
+		if err := reqCodec.Decoder(r.Body).Decode(v); err != nil {
+			return errors.BadRequestf("cannot decode request body: %v", err)
+		}
+		return nil
+	}
+}
+
+// send encodes the data with the response codec and sends it to the client with
+// provided status code, the response is empty if data is nil.
+func (res *resource) send(w http.ResponseWriter, r *http.Request, code int, data interface{}, err error) error {
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		if code == http.StatusOK {
+			code = http.StatusNoContent
+		}
+		w.WriteHeader(code)
+		return nil
+	}
+	var resCodec codec.Codec
+	if resCodec = ResponseCodecFromContext(r.Context()); resCodec == nil && res.codecs != nil {
+		resCodec = res.codecs.Lookup(r.Header.Get(acceptHeader))
+	}
+	if resCodec == nil {
+		return errors.NewStatusError(http.StatusNotAcceptable,
+			fmt.Errorf("unsupported response codec: %q", r.Header.Get(acceptHeader)))
+	}
+	var buf bytes.Buffer
+	if err := resCodec.Encoder(&buf).Encode(data); err != nil {
+		return err
+	}
+	w.Header().Set(contentTypeHeader, resCodec.MimeType())
+	w.WriteHeader(code)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
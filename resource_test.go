@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tiny-go/codec/driver"
+	"github.com/tiny-go/codec/driver/json"
+	"github.com/tiny-go/errors"
+)
+
+type resourceUser struct {
+	Name string `json:"name"`
+}
+
+// usersController implements all the resource interfaces except Patcher.
+type usersController struct {
+	*BaseController
+	users map[string]resourceUser
+}
+
+func (uc *usersController) List(r *http.Request) (interface{}, error) {
+	names := make([]string, 0, len(uc.users))
+	for id := range uc.users {
+		names = append(names, id)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (uc *usersController) Create(r *http.Request, decode func(interface{}) error) (interface{}, error) {
+	var user resourceUser
+	if err := decode(&user); err != nil {
+		return nil, err
+	}
+	uc.users[strings.ToLower(user.Name)] = user
+	return user, nil
+}
+
+func (uc *usersController) Get(r *http.Request, id string) (interface{}, error) {
+	if user, ok := uc.users[id]; ok {
+		return user, nil
+	}
+	return nil, errors.NotFoundf("user %q not found", id)
+}
+
+func (uc *usersController) Update(r *http.Request, id string, decode func(interface{}) error) (interface{}, error) {
+	var user resourceUser
+	if err := decode(&user); err != nil {
+		return nil, err
+	}
+	uc.users[id] = user
+	return nil, nil
+}
+
+func (uc *usersController) Delete(r *http.Request, id string) error {
+	delete(uc.users, id)
+	return nil
+}
+
+func Test_Router_Resource(t *testing.T) {
+	type testCase struct {
+		title   string
+		method  string
+		path    string
+		body    string
+		headers map[string]string
+		code    int
+		out     string
+		deleted string
+	}
+
+	controller := &usersController{BaseController: NewBaseController(), users: map[string]resourceUser{}}
+	controller.AddMiddleware(http.MethodDelete, SetHeaders(map[string]string{"X-Deleted": "true"}))
+	router := NewRouter().Resource("/users", controller, driver.DummyRegistry{&json.JSON{}})
+
+	cases := []testCase{
+		{
+			title:   "should create the item",
+			method:  http.MethodPost,
+			path:    "/users",
+			body:    `{"name":"Alice"}`,
+			headers: map[string]string{contentTypeHeader: "application/json", acceptHeader: "application/json"},
+			code:    http.StatusCreated,
+			out:     "{\"name\":\"Alice\"}\n",
+		},
+		{
+			title:   "should fail if request body cannot be decoded",
+			method:  http.MethodPost,
+			path:    "/users",
+			body:    `{"name":`,
+			headers: map[string]string{contentTypeHeader: "application/json"},
+			code:    http.StatusBadRequest,
+			out:     "cannot decode request body: unexpected EOF\n",
+		},
+		{
+			title:   "should fail if request codec is not supported",
+			method:  http.MethodPost,
+			path:    "/users",
+			body:    `<user/>`,
+			headers: map[string]string{contentTypeHeader: "application/xml"},
+			code:    http.StatusUnsupportedMediaType,
+			out:     "unsupported request codec: \"application/xml\"\n",
+		},
+		{
+			title:   "should list the collection",
+			method:  http.MethodGet,
+			path:    "/users",
+			headers: map[string]string{acceptHeader: "application/json"},
+			code:    http.StatusOK,
+			out:     "[\"alice\"]\n",
+		},
+		{
+			title:   "should fail if response codec is not supported",
+			method:  http.MethodGet,
+			path:    "/users",
+			headers: map[string]string{acceptHeader: "application/xml"},
+			code:    http.StatusNotAcceptable,
+			out:     "unsupported response codec: \"application/xml\"\n",
+		},
+		{
+			title:   "should get the item",
+			method:  http.MethodGet,
+			path:    "/users/alice",
+			headers: map[string]string{acceptHeader: "application/json"},
+			code:    http.StatusOK,
+			out:     "{\"name\":\"Alice\"}\n",
+		},
+		{
+			title:   "should send the error of the controller",
+			method:  http.MethodGet,
+			path:    "/users/bob",
+			headers: map[string]string{acceptHeader: "application/json"},
+			code:    http.StatusNotFound,
+			out:     "user \"bob\" not found\n",
+		},
+		{
+			title:   "should update the item without response body",
+			method:  http.MethodPut,
+			path:    "/users/alice",
+			body:    `{"name":"Alice Smith"}`,
+			headers: map[string]string{contentTypeHeader: "application/json"},
+			code:    http.StatusNoContent,
+		},
+		{
+			title:  "should not allow the methods that are not implemented",
+			method: http.MethodPatch,
+			path:   "/users/alice",
+			code:   http.StatusMethodNotAllowed,
+			out:    "Method Not Allowed\n",
+		},
+		{
+			title:  "should respond with 404 if item ID is missing",
+			method: http.MethodGet,
+			path:   "/users/",
+			code:   http.StatusNotFound,
+			out:    "Not Found\n",
+		},
+		{
+			title:   "should delete the item applying the middleware of the method",
+			method:  http.MethodDelete,
+			path:    "/users/alice",
+			code:    http.StatusNoContent,
+			deleted: "true",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			for key, value := range tc.headers {
+				r.Header.Set(key, value)
+			}
+			router.ServeHTTP(w, r)
+			if w.Code != tc.code {
+				t.Errorf("status code %d was expected to be %d", w.Code, tc.code)
+			}
+			if w.Body.String() != tc.out {
+				t.Errorf("the body %q was expected to be %q", w.Body.String(), tc.out)
+			}
+			if deleted := w.Header().Get("X-Deleted"); deleted != tc.deleted {
+				t.Errorf("header value %q was expected to be %q", deleted, tc.deleted)
+			}
+		})
+	}
+
+	if _, ok := controller.users["alice"]; ok {
+		t.Error("the item was expected to be deleted")
+	}
+
+	var routes []string
+	for route := range router.Routes() {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	expected := "DELETE /users/{id}, GET /users, GET /users/{id}, POST /users, PUT /users/{id}"
+	if strings.Join(routes, ", ") != expected {
+		t.Errorf("routes %v were expected to be %q", routes, expected)
+	}
+}
+
+func Test_Router_WithErrorRenderer(t *testing.T) {
+	controller := &usersController{BaseController: NewBaseController(), users: map[string]resourceUser{}}
+	router := NewRouter().WithErrorRenderer(RenderProblem).Resource("/users", controller, driver.DummyRegistry{&json.JSON{}})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/users/bob", nil)
+	r.Header.Set(acceptHeader, "application/json")
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status code %d was expected to be %d", w.Code, http.StatusNotFound)
+	}
+	body := "{\"type\":\"about:blank\",\"title\":\"Not Found\",\"status\":404,\"detail\":\"user \\\"bob\\\" not found\"}\n"
+	if w.Body.String() != body {
+		t.Errorf("the body %q was expected to be %q", w.Body.String(), body)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/users", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status code %d was expected to be %d", w.Code, http.StatusMethodNotAllowed)
+	}
+	if ctype := w.Header().Get(contentTypeHeader); ctype != MimeTypeProblemJSON {
+		t.Errorf("content type %q was expected to be %q", ctype, MimeTypeProblemJSON)
+	}
+}
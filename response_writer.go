@@ -0,0 +1,231 @@
+package middleware
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ResponseWriter is a wrapper of http.ResponseWriter that records the state of
+// the response, it can be shared by middleware that need to know the status code
+// (logging, metrics etc). Use NewResponseWriter to create it.
+type ResponseWriter interface {
+	http.ResponseWriter
+	// Status returns the status code of the response (0 if it has not been sent yet).
+	Status() int
+	// BytesWritten returns the number of bytes of the response body.
+	BytesWritten() int64
+	// Written reports whether the status code has been sent.
+	Written() bool
+	// StartTime returns the time when the writer was created.
+	StartTime() time.Time
+	// Duration returns the time elapsed since the writer was created.
+	Duration() time.Duration
+	// Unwrap returns the original writer (to be used by http.ResponseController).
+	Unwrap() http.ResponseWriter
+}
+
+// NewResponseWriter wraps provided writer keeping optional interfaces it supports
+// (http.Flusher, http.Hijacker, http.Pusher and io.ReaderFrom). The writer is
+// returned as is if it has been already wrapped.
+func NewResponseWriter(w http.ResponseWriter) ResponseWriter {
+	if rw, ok := w.(ResponseWriter); ok {
+		return rw
+	}
+	return wrapWriter(&responseWriter{w: w, start: time.Now()}, w)
+}
+
+// wrapWriter hides optional interfaces of responseWriter that are not supported
+// by the original writer (which can differ from rw.w if the latter is a writer
+// of some middleware implementing all of them on top of the original one).
+func wrapWriter(rw *responseWriter, w http.ResponseWriter) ResponseWriter {
+	const (
+		flusher = 1 << iota
+		hijacker
+		pusher
+		readerFrom
+	)
+	var kind int
+	if _, ok := w.(http.Flusher); ok {
+		kind |= flusher
+	}
+	if _, ok := w.(http.Hijacker); ok {
+		kind |= hijacker
+	}
+	if _, ok := w.(http.Pusher); ok {
+		kind |= pusher
+	}
+	if _, ok := w.(io.ReaderFrom); ok {
+		kind |= readerFrom
+	}
+	switch kind {
+	case flusher:
+		return struct {
+			ResponseWriter
+			http.Flusher
+		}{rw, rw}
+	case hijacker:
+		return struct {
+			ResponseWriter
+			http.Hijacker
+		}{rw, rw}
+	case pusher:
+		return struct {
+			ResponseWriter
+			http.Pusher
+		}{rw, rw}
+	case readerFrom:
+		return struct {
+			ResponseWriter
+			io.ReaderFrom
+		}{rw, rw}
+	case flusher | hijacker:
+		return struct {
+			ResponseWriter
+			http.Flusher
+			http.Hijacker
+		}{rw, rw, rw}
+	case flusher | pusher:
+		return struct {
+			ResponseWriter
+			http.Flusher
+			http.Pusher
+		}{rw, rw, rw}
+	case flusher | readerFrom:
+		return struct {
+			ResponseWriter
+			http.Flusher
+			io.ReaderFrom
+		}{rw, rw, rw}
+	case hijacker | pusher:
+		return struct {
+			ResponseWriter
+			http.Hijacker
+			http.Pusher
+		}{rw, rw, rw}
+	case hijacker | readerFrom:
+		return struct {
+			ResponseWriter
+			http.Hijacker
+			io.ReaderFrom
+		}{rw, rw, rw}
+	case pusher | readerFrom:
+		return struct {
+			ResponseWriter
+			http.Pusher
+			io.ReaderFrom
+		}{rw, rw, rw}
+	case flusher | hijacker | pusher:
+		return struct {
+			ResponseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{rw, rw, rw, rw}
+	case flusher | hijacker | readerFrom:
+		return struct {
+			ResponseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{rw, rw, rw, rw}
+	case flusher | pusher | readerFrom:
+		return struct {
+			ResponseWriter
+			http.Flusher
+			http.Pusher
+			io.ReaderFrom
+		}{rw, rw, rw, rw}
+	case hijacker | pusher | readerFrom:
+		return struct {
+			ResponseWriter
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{rw, rw, rw, rw}
+	case flusher | hijacker | pusher | readerFrom:
+		return struct {
+			ResponseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{rw, rw, rw, rw, rw}
+	default:
+		return struct{ ResponseWriter }{rw}
+	}
+}
+
+// responseWriter implements all the optional interfaces, NewResponseWriter hides
+// the ones that are not supported by the original writer.
+type responseWriter struct {
+	w       http.ResponseWriter
+	status  int
+	written int64
+	start   time.Time
+	// beforeWriteHeader (if set) is called right before the status code is sent
+	beforeWriteHeader func(http.Header)
+}
+
+// Header returns the headers of the original writer.
+func (rw *responseWriter) Header() http.Header { return rw.w.Header() }
+
+// WriteHeader records and sends the status code (only the first call is recorded).
+func (rw *responseWriter) WriteHeader(code int) {
+	rw.writeHeader(code)
+	rw.w.WriteHeader(code)
+}
+
+// writeHeader records the status code if it has not been recorded yet, informational
+// responses (1xx) are not recorded since they are followed by the final one.
+func (rw *responseWriter) writeHeader(code int) {
+	if rw.status != 0 || code < http.StatusOK {
+		return
+	}
+	if rw.beforeWriteHeader != nil {
+		rw.beforeWriteHeader(rw.w.Header())
+	}
+	rw.status = code
+}
+
+// Write sends the data (with the status 200 if it has not been sent) and counts
+// the number of bytes written.
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	rw.writeHeader(http.StatusOK)
+	n, err := rw.w.Write(p)
+	rw.written += int64(n)
+	return n, err
+}
+
+func (rw *responseWriter) Status() int                 { return rw.status }
+func (rw *responseWriter) BytesWritten() int64         { return rw.written }
+func (rw *responseWriter) Written() bool               { return rw.status != 0 }
+func (rw *responseWriter) StartTime() time.Time        { return rw.start }
+func (rw *responseWriter) Duration() time.Duration     { return time.Since(rw.start) }
+func (rw *responseWriter) Unwrap() http.ResponseWriter { return rw.w }
+
+// Flush implements http.Flusher.
+func (rw *responseWriter) Flush() {
+	rw.writeHeader(http.StatusOK)
+	rw.w.(http.Flusher).Flush()
+}
+
+// Hijack implements http.Hijacker.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return rw.w.(http.Hijacker).Hijack()
+}
+
+// Push implements http.Pusher.
+func (rw *responseWriter) Push(target string, opts *http.PushOptions) error {
+	return rw.w.(http.Pusher).Push(target, opts)
+}
+
+// ReadFrom implements io.ReaderFrom.
+func (rw *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	rw.writeHeader(http.StatusOK)
+	n, err := rw.w.(io.ReaderFrom).ReadFrom(r)
+	rw.written += n
+	return n, err
+}
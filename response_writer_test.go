@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// plainWriter does not implement any of optional interfaces.
+type plainWriter struct{ rec *httptest.ResponseRecorder }
+
+func (pw plainWriter) Header() http.Header         { return pw.rec.Header() }
+func (pw plainWriter) Write(p []byte) (int, error) { return pw.rec.Write(p) }
+func (pw plainWriter) WriteHeader(code int)        { pw.rec.WriteHeader(code) }
+
+// fullWriter implements all supported optional interfaces.
+type fullWriter struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+	pushed   string
+}
+
+func (fw *fullWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	fw.hijacked = true
+	return nil, nil, nil
+}
+
+func (fw *fullWriter) Push(target string, _ *http.PushOptions) error {
+	fw.pushed = target
+	return nil
+}
+
+func (fw *fullWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(fw.ResponseRecorder, r)
+}
+
+func Test_NewResponseWriter(t *testing.T) {
+	type testCase struct {
+		title      string
+		writer     http.ResponseWriter
+		flusher    bool
+		hijacker   bool
+		pusher     bool
+		readerFrom bool
+	}
+
+	cases := []testCase{
+		{
+			title:  "should not implement optional interfaces if original writer does not",
+			writer: plainWriter{httptest.NewRecorder()},
+		},
+		{
+			title:   "should keep http.Flusher of the original writer",
+			writer:  httptest.NewRecorder(),
+			flusher: true,
+		},
+		{
+			title:      "should keep all the optional interfaces of the original writer",
+			writer:     &fullWriter{ResponseRecorder: httptest.NewRecorder()},
+			flusher:    true,
+			hijacker:   true,
+			pusher:     true,
+			readerFrom: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			rw := NewResponseWriter(tc.writer)
+			if _, ok := rw.(http.Flusher); ok != tc.flusher {
+				t.Errorf("implementation of http.Flusher %t was expected to be %t", ok, tc.flusher)
+			}
+			if _, ok := rw.(http.Hijacker); ok != tc.hijacker {
+				t.Errorf("implementation of http.Hijacker %t was expected to be %t", ok, tc.hijacker)
+			}
+			if _, ok := rw.(http.Pusher); ok != tc.pusher {
+				t.Errorf("implementation of http.Pusher %t was expected to be %t", ok, tc.pusher)
+			}
+			if _, ok := rw.(io.ReaderFrom); ok != tc.readerFrom {
+				t.Errorf("implementation of io.ReaderFrom %t was expected to be %t", ok, tc.readerFrom)
+			}
+			if rw.Unwrap() != tc.writer {
+				t.Error("original writer was expected to be unwrapped")
+			}
+			if NewResponseWriter(rw) != rw {
+				t.Error("wrapped writer was not expected to be wrapped again")
+			}
+		})
+	}
+}
+
+func Test_ResponseWriter(t *testing.T) {
+	t.Run("should record the state of the response", func(t *testing.T) {
+		rw := NewResponseWriter(httptest.NewRecorder())
+		if rw.Written() || rw.Status() != 0 {
+			t.Errorf("writer was not expected to be written (status %d)", rw.Status())
+		}
+		rw.WriteHeader(http.StatusCreated)
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte("created"))
+		if !rw.Written() {
+			t.Error("writer was expected to be written")
+		}
+		if rw.Status() != http.StatusCreated {
+			t.Errorf("status code %d was expected to be %d", rw.Status(), http.StatusCreated)
+		}
+		if rw.BytesWritten() != 7 {
+			t.Errorf("bytes written %d was expected to be 7", rw.BytesWritten())
+		}
+		if rw.Duration() <= 0 || rw.StartTime().IsZero() {
+			t.Error("duration was expected to be measured")
+		}
+	})
+
+	t.Run("should not record informational responses", func(t *testing.T) {
+		var hooks int
+		rw := wrapWriter(&responseWriter{
+			w:                 httptest.NewRecorder(),
+			beforeWriteHeader: func(http.Header) { hooks++ },
+		}, httptest.NewRecorder())
+		rw.WriteHeader(103) // Early Hints
+		if rw.Written() || rw.Status() != 0 {
+			t.Errorf("writer was not expected to be written (status %d)", rw.Status())
+		}
+		rw.WriteHeader(http.StatusCreated)
+		if rw.Status() != http.StatusCreated {
+			t.Errorf("status code %d was expected to be %d", rw.Status(), http.StatusCreated)
+		}
+		if hooks != 1 {
+			t.Errorf("the hook was called %d times instead of once", hooks)
+		}
+	})
+
+	t.Run("should pass optional interfaces through to the original writer", func(t *testing.T) {
+		fw := &fullWriter{ResponseRecorder: httptest.NewRecorder()}
+		rw := NewResponseWriter(fw)
+		if n, err := rw.(io.ReaderFrom).ReadFrom(strings.NewReader("data")); err != nil || n != 4 {
+			t.Errorf("unexpected result of ReadFrom: %d, %v", n, err)
+		}
+		rw.(http.Hijacker).Hijack()
+		rw.(http.Pusher).Push("/style.css", nil)
+		rw.(http.Flusher).Flush()
+		if rw.Status() != http.StatusOK || rw.BytesWritten() != 4 {
+			t.Errorf("unexpected state of the response: %d, %d", rw.Status(), rw.BytesWritten())
+		}
+		if fw.Body.String() != "data" || !fw.Flushed || !fw.hijacked || fw.pushed != "/style.css" {
+			t.Error("calls were expected to be passed to the original writer")
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tiny-go/errors"
+)
+
+const allowHeader = "Allow"
+
+// ServeController builds a handler that dispatches requests to the handlers by
+// HTTP method applying the middleware of the controller registered for that
+// method. The chains are built once and cached, the controllers implementing
+// Revisioned (e.g. BaseController and the controllers embedding it) get their
+// chains rebuilt once the revision is changed. It also:
+//   - falls back to GET handler (and its middleware) for HEAD requests
+//   - responds to OPTIONS requests with 204 (No Content) and "Allow" header (unless
+//     OPTIONS handler is provided), the middleware registered for OPTIONS is applied
+//   - responds with 405 (Method Not Allowed) and "Allow" header to other requests
+//     applying the middleware registered for AnyMethod
+func ServeController(c Controller, handlers map[string]http.Handler) http.Handler {
+	return NewControllerHandler(nil, c, handlers)
+}
+
+// NewControllerHandler is the same as ServeController but it sends 405 (Method
+// Not Allowed) errors with provided renderer (RenderError by default), e.g. use
+// RenderProblem in order to send problem details.
+func NewControllerHandler(render ErrorRenderer, c Controller, handlers map[string]http.Handler) http.Handler {
+	if render == nil {
+		render = RenderError
+	}
+	allowed := make(map[string]bool, len(handlers)+2)
+	for method := range handlers {
+		allowed[method] = true
+	}
+	if allowed[http.MethodGet] {
+		allowed[http.MethodHead] = true
+	}
+	allowed[http.MethodOptions] = true
+	methods := make([]string, 0, len(allowed))
+	for method := range allowed {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	allow := strings.Join(methods, ", ")
+
+	targets := make(map[string]target, len(methods)+1)
+	for method, handler := range handlers {
+		targets[method] = target{method: method, handler: handler}
+	}
+	if _, ok := targets[http.MethodHead]; !ok && allowed[http.MethodHead] {
+		targets[http.MethodHead] = targets[http.MethodGet]
+	}
+	if _, ok := targets[http.MethodOptions]; !ok {
+		targets[http.MethodOptions] = target{
+			method: http.MethodOptions,
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(allowHeader, allow)
+				w.WriteHeader(http.StatusNoContent)
+			}),
+		}
+	}
+	notAllowed := target{
+		method: AnyMethod,
+		handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(allowHeader, allow)
+			render(w, r, errors.MethodNotAllowed(http.StatusText(http.StatusMethodNotAllowed)))
+		}),
+	}
+
+	cc := &controllerChains{controller: c, targets: targets, notAllowed: notAllowed}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cc.handler(r.Method).ServeHTTP(w, r)
+	})
+}
+
+// target is the handler of HTTP method and the method of its middleware.
+type target struct {
+	method  string
+	handler http.Handler
+}
+
+// Revisioned can be implemented by the controllers which count the changes of
+// their middleware (see BaseController), ServeController rebuilds the chains of
+// the controller when its revision is changed.
+type Revisioned interface {
+	Revision() uint64
+}
+
+// builtChains contains the chains built with the revision of the controller.
+type builtChains struct {
+	revision   uint64
+	chains     map[string]http.Handler
+	notAllowed http.Handler
+}
+
+// controllerChains builds (and caches) the chains of the controller handlers.
+type controllerChains struct {
+	controller Controller
+	targets    map[string]target
+	notAllowed target
+	mu         sync.Mutex
+	built      atomic.Value // *builtChains
+}
+
+// handler returns the chain of the handler of HTTP method.
+func (cc *controllerChains) handler(method string) http.Handler {
+	// the chains of other controllers are built only once
+	var revision uint64
+	if rc, ok := cc.controller.(Revisioned); ok {
+		revision = rc.Revision()
+	}
+	built := cc.load(revision)
+	if chain, ok := built.chains[method]; ok {
+		return chain
+	}
+	return built.notAllowed
+}
+
+// load returns the chains of the revision rebuilding them if necessary.
+func (cc *controllerChains) load(revision uint64) *builtChains {
+	if built, _ := cc.built.Load().(*builtChains); built != nil && built.revision == revision {
+		return built
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if built, _ := cc.built.Load().(*builtChains); built != nil && built.revision == revision {
+		return built
+	}
+	// the revision is taken before building, so the chains are rebuilt again if
+	// the controller is changed in the meantime
+	built := &builtChains{revision: revision, chains: make(map[string]http.Handler, len(cc.targets))}
+	for method, t := range cc.targets {
+		built.chains[method] = cc.controller.Middleware(t.method).Then(t.handler)
+	}
+	built.notAllowed = cc.controller.Middleware(cc.notAllowed.method).Then(cc.notAllowed.handler)
+	cc.built.Store(built)
+	return built
+}
+
+// route contains the controller serving HTTP method.
+type route struct {
+	method     string
+	path       string
+	controller Controller
+	// describes the handler of the route (if known)
+	doc *APIDoc
+}
+
+// Router mounts controllers to the paths (see http.ServeMux for path patterns).
+type Router struct {
+	mu     sync.RWMutex
+	mux    *http.ServeMux
+	routes []route
+	// sends the errors of resource handlers
+	render ErrorRenderer
+}
+
+// NewRouter is a constructor func for Router.
+func NewRouter() *Router {
+	return &Router{mux: http.NewServeMux()}
+}
+
+// WithErrorRenderer sets the renderer of the errors returned by resource controllers
+// and 405 (Method Not Allowed) errors (RenderError by default), it should be called
+// before mounting the controllers.
+func (rt *Router) WithErrorRenderer(render ErrorRenderer) *Router {
+	rt.render = render
+	return rt
+}
+
+// Mount serves the controller with provided handlers at the path (see ServeController).
+func (rt *Router) Mount(path string, c Controller, handlers map[string]http.Handler) *Router {
+	return rt.mount(path, path, c, handlers, nil)
+}
+
+// mount serves the controller at the pattern, routes are listed with provided path
+// and the descriptions of the handlers.
+func (rt *Router) mount(pattern, path string, c Controller, handlers map[string]http.Handler, docs map[string]APIDoc) *Router {
+	rt.mux.Handle(pattern, NewControllerHandler(rt.render, c, handlers))
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	methods := make([]string, 0, len(handlers))
+	for method := range handlers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		r := route{method: method, path: path, controller: c}
+		if doc, ok := docs[method]; ok {
+			r.doc = &doc
+		}
+		rt.routes = append(rt.routes, r)
+	}
+	return rt
+}
+
+// Routes returns the middleware of all the mounted handlers, the keys of the map
+// have format "METHOD path" (see DebugChains). The middleware of Resource routes
+// also describes the responses of the handlers (see NewOpenAPI).
+func (rt *Router) Routes() map[string]Middleware {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	routes := make(map[string]Middleware, len(rt.routes))
+	for _, route := range rt.routes {
+		mw := route.controller.Middleware(route.method)
+		if route.doc != nil {
+			// the description of the handler is added for OpenAPI document
+			mw = mw.Use(Documented(*route.doc, New()))
+		}
+		routes[route.method+" "+route.path] = mw
+	}
+	return routes
+}
+
+// ServeHTTP dispatches the request to the controller mounted to the path.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
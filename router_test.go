@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func Test_ServeController(t *testing.T) {
+	type testCase struct {
+		title  string
+		method string
+		code   int
+		allow  string
+		body   string
+	}
+
+	controller := NewBaseController()
+	controller.AddMiddleware(http.MethodGet, middlewareOne)
+	controller.AddMiddleware(http.MethodOptions, SetHeaders(map[string]string{"Access-Control-Allow-Origin": "*"}))
+
+	handler := ServeController(controller, map[string]http.Handler{
+		http.MethodGet:  handlerFinal,
+		http.MethodPost: http.HandlerFunc(handlerOne),
+	})
+
+	cases := []testCase{
+		{
+			title:  "should apply the middleware of the method",
+			method: http.MethodGet,
+			code:   http.StatusOK,
+			body:   "/mw1 before next/final handler/mw1 after next",
+		},
+		{
+			title:  "should call the handler without middleware",
+			method: http.MethodPost,
+			code:   http.StatusOK,
+			body:   "/first handler",
+		},
+		{
+			title:  "should fall back to GET handler for HEAD requests",
+			method: http.MethodHead,
+			code:   http.StatusOK,
+			body:   "/mw1 before next/final handler/mw1 after next",
+		},
+		{
+			title:  "should answer OPTIONS requests automatically",
+			method: http.MethodOptions,
+			code:   http.StatusNoContent,
+			allow:  "GET, HEAD, OPTIONS, POST",
+		},
+		{
+			title:  "should respond with 405 to the methods without handlers",
+			method: http.MethodDelete,
+			code:   http.StatusMethodNotAllowed,
+			allow:  "GET, HEAD, OPTIONS, POST",
+			body:   "Method Not Allowed\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tc.method, "/", nil))
+			if w.Code != tc.code {
+				t.Errorf("status code %d was expected to be %d", w.Code, tc.code)
+			}
+			if allow := w.Header().Get(allowHeader); allow != tc.allow {
+				t.Errorf("Allow header %q was expected to be %q", allow, tc.allow)
+			}
+			if w.Body.String() != tc.body {
+				t.Errorf("the body %q was expected to be %q", w.Body.String(), tc.body)
+			}
+		})
+	}
+
+	t.Run("should apply OPTIONS middleware to automatic response", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/", nil))
+		if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
+			t.Errorf("header value %q was expected to be %q", origin, "*")
+		}
+	})
+
+	t.Run("should apply the middleware changed after building the handler", func(t *testing.T) {
+		controller := NewBaseController()
+		handler := ServeController(controller, map[string]http.Handler{http.MethodGet: handlerFinal})
+		for _, step := range []struct {
+			change func()
+			body   string
+		}{
+			{func() {}, "/final handler"},
+			{func() { controller.AddMiddleware(http.MethodGet, middlewareOne) }, "/mw1 before next/final handler/mw1 after next"},
+			{func() { controller.SetMiddleware(http.MethodGet, middlewareTwo) }, "/mw2 before next/final handler/mw2 after next"},
+			{func() { controller.ResetMiddleware(http.MethodGet) }, "/final handler"},
+		} {
+			step.change()
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Body.String() != step.body {
+				t.Errorf("the body %q was expected to be %q", w.Body.String(), step.body)
+			}
+		}
+	})
+
+	t.Run("should apply AnyMethod middleware to unsupported methods", func(t *testing.T) {
+		controller := NewBaseController()
+		controller.AddMiddleware(AnyMethod, SetHeaders(map[string]string{"Access-Control-Allow-Origin": "*"}))
+		controller.AddMiddleware(http.MethodDelete, middlewareOne)
+		w := httptest.NewRecorder()
+		ServeController(controller, map[string]http.Handler{http.MethodGet: handlerFinal}).
+			ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/", nil))
+		if w.Code != http.StatusMethodNotAllowed || w.Body.String() != "Method Not Allowed\n" {
+			t.Errorf("unexpected response: %d %q", w.Code, w.Body.String())
+		}
+		if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
+			t.Errorf("header value %q was expected to be %q", origin, "*")
+		}
+	})
+}
+
+// countingController builds the chains with the middleware counting the builds.
+type countingController struct {
+	builds   int
+	revision uint64
+}
+
+func (cc *countingController) AddMiddleware(string, ...Middleware) Controller { return cc }
+
+func (cc *countingController) Middleware(string) Middleware {
+	return New(func(next http.Handler) http.Handler {
+		cc.builds++
+		return next
+	})
+}
+
+// revisionedController can change the revision of its middleware.
+type revisionedController struct {
+	countingController
+}
+
+func (rc *revisionedController) Revision() uint64 { return rc.revision }
+
+func Test_ServeController_cache(t *testing.T) {
+	handlers := map[string]http.Handler{http.MethodGet: handlerFinal}
+
+	t.Run("should build the chains of other controllers once", func(t *testing.T) {
+		controller := &countingController{}
+		handler := ServeController(controller, handlers)
+		for i := 0; i < 10; i++ {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}
+		// GET, HEAD, OPTIONS and 405 chains
+		if controller.builds != 4 {
+			t.Errorf("the chains were built %d times instead of 4", controller.builds)
+		}
+	})
+
+	t.Run("should rebuild the chains when the revision is changed", func(t *testing.T) {
+		controller := &revisionedController{}
+		handler := ServeController(controller, handlers)
+		for i := 0; i < 10; i++ {
+			if i == 5 {
+				controller.revision++
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}
+		if controller.builds != 8 {
+			t.Errorf("the chains were built %d times instead of 8", controller.builds)
+		}
+	})
+}
+
+func Test_Router(t *testing.T) {
+	users := NewBaseController()
+	users.AddMiddleware(http.MethodGet, Named("auth", middlewareOne))
+	router := NewRouter().
+		Mount("/users", users, map[string]http.Handler{http.MethodGet: handlerFinal, http.MethodPost: handlerFinal}).
+		Mount("/health", NewBaseController(), map[string]http.Handler{http.MethodGet: http.HandlerFunc(handlerOne)})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if out := "/mw1 before next/final handler/mw1 after next"; w.Body.String() != out {
+		t.Errorf("the output %q is expected to be %q", w.Body.String(), out)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/health", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status code %d was expected to be %d", w.Code, http.StatusMethodNotAllowed)
+	}
+
+	var routes []string
+	for route := range router.Routes() {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	if expected := []string{"GET /health", "GET /users", "POST /users"}; !reflect.DeepEqual(routes, expected) {
+		t.Errorf("routes %v were expected to be %v", routes, expected)
+	}
+}
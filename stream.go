@@ -0,0 +1,234 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tiny-go/codec"
+)
+
+const (
+	// MimeTypeNDJSON is a mime type of newline delimited JSON stream.
+	MimeTypeNDJSON = "application/x-ndjson"
+	// MimeTypeEventStream is a mime type of Server-Sent Events stream.
+	MimeTypeEventStream = "text/event-stream"
+)
+
+var (
+	// ErrStreamingUnsupported - response writer cannot be flushed.
+	ErrStreamingUnsupported = errors.New("streaming is not supported by the response writer")
+	// ErrNoResponseCodec - response codec was not found in the request context.
+	ErrNoResponseCodec = errors.New("no response codec in the context")
+	// ErrInvalidEvent - event ID or name contains line breaks (they would break
+	// the event into several fields).
+	ErrInvalidEvent = errors.New("event ID and name cannot contain line breaks")
+)
+
+// eventLineBreaks replaces all the line breaks of event data with "\n" (CR, LF
+// and CRLF are line breaks of event stream).
+var eventLineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// streamCodecs contains built-in streaming codecs, they are used by Codec middleware
+// when provided registry does not contain any codec for the requested type.
+var streamCodecs = []codec.Codec{&NDJSON{}, &EventStream{}}
+
+// lookupStreamCodec returns built-in streaming codec by mime type or nil.
+func lookupStreamCodec(mimeType string) codec.Codec {
+	for _, c := range streamCodecs {
+		if c.MimeType() == mimeType {
+			return c
+		}
+	}
+	return nil
+}
+
+// NDJSON is a newline delimited JSON codec, every encoded value is written as a
+// single line.
+type NDJSON struct{}
+
+// Encoder creates NDJSON encoder.
+func (*NDJSON) Encoder(w io.Writer) codec.Encoder { return json.NewEncoder(w) }
+
+// Decoder creates NDJSON decoder (reads values one by one).
+func (*NDJSON) Decoder(r io.Reader) codec.Decoder { return json.NewDecoder(r) }
+
+// MimeType returns the mime type of this codec (which is application/x-ndjson).
+func (*NDJSON) MimeType() string { return MimeTypeNDJSON }
+
+// Event represents a single Server-Sent Event. Values that are not of type Event
+// (or *Event) are sent by EventStream encoder as event data without any additional
+// fields.
+type Event struct {
+	// ID sets the last event ID of the client.
+	ID string
+	// Name is an event type (the client receives "message" event if empty).
+	Name string
+	// Retry is a reconnection time (ignored if zero).
+	Retry time.Duration
+	// Data is encoded with data codec of the EventStream.
+	Data interface{}
+}
+
+// EventStream is a Server-Sent Events codec. Event data is encoded with Data
+// codec (JSON is used if not specified). Decoding is not supported.
+type EventStream struct {
+	Data codec.Codec
+}
+
+// Encoder creates Server-Sent Events encoder.
+func (es *EventStream) Encoder(w io.Writer) codec.Encoder {
+	return codec.EncoderFunc(func(v interface{}) error {
+		var event Event
+		switch e := v.(type) {
+		case Event:
+			event = e
+		case *Event:
+			event = *e
+		default:
+			event = Event{Data: v}
+		}
+		return es.writeEvent(w, event)
+	})
+}
+
+// Decoder returns a decoder that always fails since event stream is a write-only
+// format for the server.
+func (*EventStream) Decoder(io.Reader) codec.Decoder {
+	return codec.DecoderFunc(func(interface{}) error {
+		return fmt.Errorf("decoding %q is not supported", MimeTypeEventStream)
+	})
+}
+
+// MimeType returns the mime type of this codec (which is text/event-stream).
+func (*EventStream) MimeType() string { return MimeTypeEventStream }
+
+// writeEvent writes an event in "text/event-stream" format, it fails with
+// ErrInvalidEvent if ID or name of the event contains line breaks.
+func (es *EventStream) writeEvent(w io.Writer, event Event) error {
+	if strings.ContainsAny(event.ID, "\r\n") || strings.ContainsAny(event.Name, "\r\n") {
+		return ErrInvalidEvent
+	}
+	var buf bytes.Buffer
+	if event.ID != "" {
+		fmt.Fprintf(&buf, "id: %s\n", event.ID)
+	}
+	if event.Name != "" {
+		fmt.Fprintf(&buf, "event: %s\n", event.Name)
+	}
+	if event.Retry > 0 {
+		fmt.Fprintf(&buf, "retry: %d\n", event.Retry/time.Millisecond)
+	}
+	if event.Data != nil {
+		data, err := es.encodeData(event.Data)
+		if err != nil {
+			return err
+		}
+		// every line of the payload should be sent as a separate data field
+		lines := eventLineBreaks.Replace(strings.TrimRight(string(data), "\r\n"))
+		for _, line := range strings.Split(lines, "\n") {
+			fmt.Fprintf(&buf, "data: %s\n", line)
+		}
+	}
+	buf.WriteByte('\n')
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// encodeData encodes event payload with data codec, strings and byte slices are
+// sent as is.
+func (es *EventStream) encodeData(v interface{}) ([]byte, error) {
+	switch data := v.(type) {
+	case string:
+		return []byte(data), nil
+	case []byte:
+		return data, nil
+	}
+	var buf bytes.Buffer
+	if es.Data == nil {
+		err := json.NewEncoder(&buf).Encode(v)
+		return buf.Bytes(), err
+	}
+	err := es.Data.Encoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+// Stream sends items to the client one by one flushing every item.
+type Stream interface {
+	// Send should encode provided item, write it to the client and flush. It should
+	// return an error if request context is done.
+	Send(v interface{}) error
+}
+
+// stream is a basic Stream implementation.
+type stream struct {
+	sync.Mutex
+	ctx     context.Context
+	encoder codec.Encoder
+	flusher http.Flusher
+}
+
+// NewStream creates a Stream using the response codec negotiated by Codec middleware.
+// Each item is encoded separately, thus it works for streaming codecs (NDJSON,
+// EventStream) as well as for the regular ones (every value is written as a separate
+// document).
+//
+// Example:
+//
+//	func export(w http.ResponseWriter, r *http.Request) {
+//	    stream, err := middleware.NewStream(w, r)
+//	    if err != nil {
+//	        http.Error(w, err.Error(), http.StatusInternalServerError)
+//	        return
+//	    }
+//	    for item := range items(r.Context()) {
+//	        if err := stream.Send(item); err != nil {
+//	            return
+//	        }
+//	    }
+//	}
+func NewStream(w http.ResponseWriter, r *http.Request) (Stream, error) {
+	c := ResponseCodecFromContext(r.Context())
+	if c == nil {
+		return nil, ErrNoResponseCodec
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, ErrStreamingUnsupported
+	}
+	if c.MimeType() == MimeTypeEventStream {
+		setEventStreamHeaders(w.Header())
+	} else {
+		w.Header().Set(contentTypeHeader, c.MimeType())
+	}
+	return &stream{ctx: r.Context(), encoder: c.Encoder(w), flusher: flusher}, nil
+}
+
+// setEventStreamHeaders sets the headers of the event stream response, proxies
+// should not cache or buffer the events.
+func setEventStreamHeaders(header http.Header) {
+	header.Set(contentTypeHeader, MimeTypeEventStream)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+}
+
+// Send encodes the item and flushes the response.
+func (s *stream) Send(v interface{}) error {
+	s.Lock()
+	defer s.Unlock()
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if err := s.encoder.Encode(v); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tiny-go/codec/driver"
+	"github.com/tiny-go/codec/driver/json"
+)
+
+type nonFlusher struct{ http.ResponseWriter }
+
+func Test_Stream(t *testing.T) {
+	type item struct {
+		ID int `json:"id"`
+	}
+
+	type testCase struct {
+		title   string
+		accept  string
+		handler http.HandlerFunc
+		ctype   string
+		code    int
+		body    string
+	}
+
+	sendItems := func(w http.ResponseWriter, r *http.Request) {
+		stream, err := NewStream(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for i := 1; i <= 3; i++ {
+			if err := stream.Send(item{ID: i}); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}
+	}
+
+	cases := []testCase{
+		{
+			title:   "should stream newline delimited JSON",
+			accept:  MimeTypeNDJSON,
+			handler: sendItems,
+			ctype:   MimeTypeNDJSON,
+			code:    http.StatusOK,
+			body:    "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n",
+		},
+		{
+			title:   "should stream Server-Sent Events",
+			accept:  MimeTypeEventStream,
+			handler: sendItems,
+			ctype:   MimeTypeEventStream,
+			code:    http.StatusOK,
+			body:    "data: {\"id\":1}\n\ndata: {\"id\":2}\n\ndata: {\"id\":3}\n\n",
+		},
+		{
+			title:  "should send all the fields of the event",
+			accept: MimeTypeEventStream,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				stream, _ := NewStream(w, r)
+				stream.Send(Event{ID: "1", Name: "update", Retry: time.Second, Data: "first\nsecond"})
+			},
+			ctype: MimeTypeEventStream,
+			code:  http.StatusOK,
+			body:  "id: 1\nevent: update\nretry: 1000\ndata: first\ndata: second\n\n",
+		},
+		{
+			title:  "should send every line of the data separately",
+			accept: MimeTypeEventStream,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				stream, _ := NewStream(w, r)
+				stream.Send(Event{Data: "first\rsecond\r\nthird\n"})
+			},
+			ctype: MimeTypeEventStream,
+			code:  http.StatusOK,
+			body:  "data: first\ndata: second\ndata: third\n\n",
+		},
+		{
+			title:  "should not send the event with line breaks in the name",
+			accept: MimeTypeEventStream,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				stream, _ := NewStream(w, r)
+				if err := stream.Send(Event{Name: "update\ndata: injected", Data: "data"}); err != ErrInvalidEvent {
+					t.Errorf("error %v was expected to be %v", err, ErrInvalidEvent)
+				}
+			},
+			ctype: MimeTypeEventStream,
+			code:  http.StatusOK,
+		},
+		{
+			title:   "should use the regular response codec from the registry",
+			accept:  "application/json",
+			handler: sendItems,
+			ctype:   "application/json",
+			code:    http.StatusOK,
+			body:    "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n",
+		},
+		{
+			title:  "should fail if response writer cannot be flushed",
+			accept: MimeTypeNDJSON,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				sendItems(nonFlusher{w}, r)
+			},
+			ctype: "text/plain; charset=utf-8",
+			code:  http.StatusInternalServerError,
+			body:  ErrStreamingUnsupported.Error() + "\n",
+		},
+	}
+
+	t.Run("Given Codec middleware with a regular codec registry", func(t *testing.T) {
+		for _, tc := range cases {
+			t.Run(tc.title, func(t *testing.T) {
+				r, _ := http.NewRequest(http.MethodGet, "", nil)
+				r.Header.Set(contentTypeHeader, "application/json")
+				r.Header.Set(acceptHeader, tc.accept)
+				w := httptest.NewRecorder()
+				Codec(nil, driver.DummyRegistry{&json.JSON{}})(tc.handler).ServeHTTP(w, r)
+				if w.Code != tc.code {
+					t.Errorf("status code %d was expected to be %d", w.Code, tc.code)
+				}
+				if ctype := w.Header().Get(contentTypeHeader); ctype != tc.ctype {
+					t.Errorf("content type %q was expected to be %q", ctype, tc.ctype)
+				}
+				if w.Body.String() != tc.body {
+					t.Errorf("response body %q was expected to be %q", w.Body.String(), tc.body)
+				}
+			})
+		}
+	})
+
+	t.Run("should fail without response codec in the context", func(t *testing.T) {
+		r, _ := http.NewRequest(http.MethodGet, "", nil)
+		if _, err := NewStream(httptest.NewRecorder(), r); err != ErrNoResponseCodec {
+			t.Errorf("error %v was expected to be %v", err, ErrNoResponseCodec)
+		}
+	})
+
+	t.Run("should stop sending when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		r, _ := http.NewRequest(http.MethodGet, "", nil)
+		r = r.WithContext(context.WithValue(ctx, codecKey{"res"}, &NDJSON{}))
+		w := httptest.NewRecorder()
+		stream, _ := NewStream(w, r)
+		if err := stream.Send(item{ID: 1}); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+		cancel()
+		if err := stream.Send(item{ID: 2}); err != context.Canceled {
+			t.Errorf("error %v was expected to be %v", err, context.Canceled)
+		}
+		if w.Body.String() != "{\"id\":1}\n" {
+			t.Errorf("response body %q contains unexpected items", w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// switchState is the middleware installed by a single Swap call.
+type switchState struct {
+	mw Middleware
+}
+
+// switchBinding is the middleware applied to a particular next handler (see
+// Switchable.Middleware), the handler is rebuilt by the first request served after
+// the middleware is replaced.
+type switchBinding struct {
+	s       *Switchable
+	next    http.Handler
+	mu      sync.Mutex
+	handler atomic.Value
+}
+
+// builtHandler wraps the handler in order to store it in atomic.Value (handlers
+// have different concrete types), it keeps the state it was built with.
+type builtHandler struct {
+	http.Handler
+	state *switchState
+}
+
+// load returns the handler built with provided state (it is built if needed).
+func (sb *switchBinding) load(state *switchState) http.Handler {
+	if built := sb.handler.Load().(builtHandler); built.state == state {
+		return built
+	}
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	built := sb.handler.Load().(builtHandler)
+	if built.state != state {
+		built = builtHandler{Handler: state.mw(sb.next), state: state}
+		sb.handler.Store(built)
+	}
+	return built
+}
+
+// ServeHTTP passes the request through currently installed middleware.
+func (sb *switchBinding) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sb.load(sb.s.current.Load().(*switchState)).ServeHTTP(w, r)
+}
+
+// Switchable holds the middleware that can be replaced at runtime (e.g. to change
+// rate limits or to enable maintenance mode without restarting the server). Every
+// chain is rebuilt by its first request after the middleware is replaced, requests
+// that are in progress are finished with the old chain.
+//
+// Example:
+//
+//	limits := mw.NewSwitchable(mw.RequestLimiter(nil, 100))
+//	http.Handle("/", mw.New(mw.RequestID, limits.Middleware()).Then(handler))
+//	// later (e.g. on SIGHUP)
+//	err := limits.Reload(func() (mw.Middleware, error) {
+//	    return loadMiddlewareFromConfig()
+//	})
+type Switchable struct {
+	mu      sync.Mutex
+	current atomic.Value
+}
+
+// NewSwitchable is a constructor func for Switchable, nil middleware is replaced
+// with an empty one.
+func NewSwitchable(mw Middleware) *Switchable {
+	s := &Switchable{}
+	s.current.Store(newSwitchState(mw))
+	return s
+}
+
+func newSwitchState(mw Middleware) *switchState {
+	if mw == nil {
+		mw = New()
+	}
+	return &switchState{mw: mw}
+}
+
+// Current returns currently installed middleware.
+func (s *Switchable) Current() Middleware {
+	return s.current.Load().(*switchState).mw
+}
+
+// Swap installs new middleware and returns the old one, nil middleware is replaced
+// with an empty one.
+func (s *Switchable) Swap(mw Middleware) Middleware {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.Current()
+	s.current.Store(newSwitchState(mw))
+	return old
+}
+
+// Reload installs the middleware returned by load func. Current middleware is
+// kept if load func returns an error or dependencies of new middleware are not
+// satisfied (see Depends).
+func (s *Switchable) Reload(load func() (Middleware, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mw, err := load()
+	if err != nil {
+		return err
+	}
+	if mw != nil {
+		if err := Verify(mw); err != nil {
+			return err
+		}
+	}
+	s.current.Store(newSwitchState(mw))
+	return nil
+}
+
+// Middleware returns the middleware that passes requests through currently
+// installed middleware.
+func (s *Switchable) Middleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		if tracerOf(next) != nil {
+			// the chain is being described (see Describe), it is not going to
+			// serve the requests
+			return s.Current()(next)
+		}
+		binding := &switchBinding{s: s, next: next}
+		state := s.current.Load().(*switchState)
+		binding.handler.Store(builtHandler{Handler: state.mw(next), state: state})
+		return binding
+	}
+}
+
+// Then injects handler into currently installed middleware (see Middleware).
+func (s *Switchable) Then(final http.Handler) http.Handler {
+	return s.Middleware()(final)
+}
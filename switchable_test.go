@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func Test_Switchable(t *testing.T) {
+	t.Run("should pass requests through currently installed middleware", func(t *testing.T) {
+		s := NewSwitchable(nil)
+		handler := s.Then(handlerFinal)
+		check := func(out string) {
+			t.Helper()
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, nil)
+			if w.Body.String() != out {
+				t.Errorf("the output %q is expected to be %q", w.Body.String(), out)
+			}
+		}
+		check("/final handler")
+		s.Swap(middlewareOne)
+		check("/mw1 before next/final handler/mw1 after next")
+		if err := s.Reload(func() (Middleware, error) { return New(middlewareTwo), nil }); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		check("/mw2 before next/final handler/mw2 after next")
+	})
+
+	t.Run("should keep current middleware if it cannot be reloaded", func(t *testing.T) {
+		s := NewSwitchable(middlewareOne)
+		handler := s.Then(handlerFinal)
+		reloadErr := errors.New("invalid config")
+		if err := s.Reload(func() (Middleware, error) { return nil, reloadErr }); err != reloadErr {
+			t.Errorf("error %v was expected to be %v", err, reloadErr)
+		}
+		if err := s.Reload(func() (Middleware, error) {
+			return Depends("logger", middlewareTwo, "request id"), nil
+		}); err == nil {
+			t.Error("error was expected for unsatisfied dependencies")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, nil)
+		if out := "/mw1 before next/final handler/mw1 after next"; w.Body.String() != out {
+			t.Errorf("the output %q is expected to be %q", w.Body.String(), out)
+		}
+	})
+
+	t.Run("should build the chain once per installed middleware", func(t *testing.T) {
+		var builds int
+		counting := func(next http.Handler) http.Handler {
+			builds++
+			return next
+		}
+		s := NewSwitchable(counting)
+		handler := s.Then(handlerFinal)
+		for i := 0; i < 3; i++ {
+			handler.ServeHTTP(httptest.NewRecorder(), nil)
+		}
+		s.Swap(counting)
+		handler.ServeHTTP(httptest.NewRecorder(), nil)
+		if builds != 2 {
+			t.Errorf("the chain was built %d times instead of 2", builds)
+		}
+	})
+
+	t.Run("should not keep the chains built many times", func(t *testing.T) {
+		var builds int
+		counting := func(next http.Handler) http.Handler {
+			builds++
+			return next
+		}
+		s := NewSwitchable(counting)
+		var handler http.Handler
+		for i := 0; i < 1000; i++ {
+			handler = s.Then(handlerFinal)
+		}
+		for i := 0; i < 10; i++ {
+			s.Swap(counting)
+		}
+		// neither swaps nor other chains rebuild the chain that serves the requests
+		// more than once
+		handler.ServeHTTP(httptest.NewRecorder(), nil)
+		handler.ServeHTTP(httptest.NewRecorder(), nil)
+		if builds != 1001 {
+			t.Errorf("the chains were built %d times instead of 1001", builds)
+		}
+	})
+
+	t.Run("should finish requests in progress with the old middleware", func(t *testing.T) {
+		s := NewSwitchable(nil)
+		started, release := make(chan struct{}), make(chan struct{})
+		handler := s.Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Wait") != "" {
+				close(started)
+				<-release
+			}
+			w.Write([]byte("/final handler"))
+		}))
+		w := httptest.NewRecorder()
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("X-Wait", "true")
+			handler.ServeHTTP(w, r)
+		}()
+		<-started
+		s.Swap(middlewareOne)
+		// concurrent requests should use new middleware
+		var concurrent sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			concurrent.Add(1)
+			go func() {
+				defer concurrent.Done()
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+				if out := "/mw1 before next/final handler/mw1 after next"; w.Body.String() != out {
+					t.Errorf("the output %q is expected to be %q", w.Body.String(), out)
+				}
+			}()
+		}
+		concurrent.Wait()
+		close(release)
+		wg.Wait()
+		if out := "/final handler"; w.Body.String() != out {
+			t.Errorf("the output %q is expected to be %q", w.Body.String(), out)
+		}
+	})
+
+	t.Run("should reload the middleware while requests are served", func(t *testing.T) {
+		s := NewSwitchable(Named("one", middlewareOne))
+		handler := New(Named("outer", s.Middleware())).Then(handlerFinal)
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+					}
+				}
+			}()
+		}
+		for i := 0; i < 100; i++ {
+			if err := s.Reload(func() (Middleware, error) {
+				return New(Named("request id", middlewareTwo), Depends("logger", middlewareThree, "request id")), nil
+			}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				break
+			}
+		}
+		close(stop)
+		wg.Wait()
+		expected := []ChainInfo{{Name: "outer", Children: []ChainInfo{{Name: "request id"}, {Name: "logger", Requires: []string{"request id"}}}}}
+		if info := Describe(New(Named("outer", s.Middleware()))); !reflect.DeepEqual(info, expected) {
+			t.Errorf("chain info %v was expected to be %v", info, expected)
+		}
+	})
+}
@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const serverTimingHeader = "Server-Timing"
+
+// Timing contains the time spent in named middleware (see Named) excluding the
+// time spent in the next handlers and in nested named middleware (self time).
+type Timing struct {
+	Name     string
+	Duration time.Duration
+}
+
+type timingsKey struct{}
+
+// timingID identifies the position of named middleware in the chain (it is created
+// every time named middleware is built).
+type timingID struct{ name string }
+
+// timingEntry measures the time of a single named middleware.
+type timingEntry struct {
+	name    string
+	total   time.Duration
+	started time.Time
+}
+
+// timings collects the time of named middleware during the request (middleware
+// may call next handler in a separate goroutine). Only the middleware on top of
+// the stack is measured, so nested middleware is not counted twice.
+type timings struct {
+	mu      sync.Mutex
+	entries []*timingEntry
+	index   map[*timingID]*timingEntry
+	stack   []*timingEntry
+}
+
+// start starts (or resumes) measuring the time of the middleware, the time of
+// the middleware that was measured before is paused.
+func (t *timings) start(id *timingID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := t.index[id]
+	if !ok {
+		entry = &timingEntry{name: id.name}
+		t.index[id] = entry
+		t.entries = append(t.entries, entry)
+	}
+	now := time.Now()
+	if top := t.top(); top != nil {
+		top.total += now.Sub(top.started)
+	}
+	entry.started = now
+	t.stack = append(t.stack, entry)
+}
+
+// stop stops (or pauses) measuring the time of the middleware and resumes the
+// one that was measured before.
+func (t *timings) stop(id *timingID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := t.index[id]
+	if !ok {
+		return
+	}
+	for i := len(t.stack) - 1; i >= 0; i-- {
+		if t.stack[i] != entry {
+			continue
+		}
+		now := time.Now()
+		if i == len(t.stack)-1 {
+			entry.total += now.Sub(entry.started)
+			if i > 0 {
+				t.stack[i-1].started = now
+			}
+		}
+		t.stack = append(t.stack[:i], t.stack[i+1:]...)
+		return
+	}
+}
+
+// top returns the middleware which is being measured (should be called under the lock).
+func (t *timings) top() *timingEntry {
+	if len(t.stack) == 0 {
+		return nil
+	}
+	return t.stack[len(t.stack)-1]
+}
+
+// list returns measured time of the middleware in the order of their execution
+// (time of running middleware is measured up to now).
+func (t *timings) list() []Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	top := t.top()
+	list := make([]Timing, 0, len(t.entries))
+	for _, entry := range t.entries {
+		total := entry.total
+		if entry == top {
+			total += time.Since(entry.started)
+		}
+		list = append(list, Timing{Name: entry.name, Duration: total})
+	}
+	return list
+}
+
+// timed wraps the middleware in order to measure the time spent in it excluding
+// the next handler.
+func timed(id *timingID, mw Middleware, next http.Handler) http.Handler {
+	handler := mw(withTracer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if t := timingsFromRequest(r); t != nil {
+			t.stop(id)
+			defer t.start(id)
+		}
+		next.ServeHTTP(w, r)
+	}), tracerOf(next)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if t := timingsFromRequest(r); t != nil {
+			t.start(id)
+			defer t.stop(id)
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// timingsFromRequest returns the timings of instrumented request (or nil).
+func timingsFromRequest(r *http.Request) *timings {
+	if r == nil {
+		return nil
+	}
+	return timingsFromContext(r.Context())
+}
+
+func timingsFromContext(ctx context.Context) *timings {
+	t, _ := ctx.Value(timingsKey{}).(*timings)
+	return t
+}
+
+// TimingsFromContext returns the time spent in named middleware so far (if the
+// request is instrumented, see Instrument).
+func TimingsFromContext(ctx context.Context) []Timing {
+	if t := timingsFromContext(ctx); t != nil {
+		return t.list()
+	}
+	return nil
+}
+
+// Instrument measures the time spent in each named middleware (see Named) of the
+// chain excluding the time of its next handlers and nested named middleware (every
+// position of the middleware in the chain is measured separately), so it should be the first one
+// in the chain. If header is true, measured time is sent with "Server-Timing"
+// response header (since it is sent with the status code, it contains only the
+// time spent before calling next handlers). Report func (optional) is called
+// with the full time of each middleware when the request is processed.
+func Instrument(header bool, report func(*http.Request, []Timing)) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t := &timings{index: make(map[*timingID]*timingEntry)}
+			r = r.WithContext(context.WithValue(r.Context(), timingsKey{}, t))
+			if header {
+				w = wrapWriter(&responseWriter{
+					w:     w,
+					start: time.Now(),
+					beforeWriteHeader: func(h http.Header) {
+						if value := serverTiming(t.list()); value != "" {
+							h.Add(serverTimingHeader, value)
+						}
+					},
+				}, w)
+			}
+			next.ServeHTTP(w, r)
+			if report != nil {
+				report(r, t.list())
+			}
+		})
+	}
+}
+
+// serverTiming formats the value of "Server-Timing" header (durations in milliseconds).
+func serverTiming(list []Timing) string {
+	metrics := make([]string, 0, len(list))
+	for _, timing := range list {
+		metrics = append(metrics, fmt.Sprintf("%s;dur=%.3f", timingToken(timing.Name), float64(timing.Duration)/float64(time.Millisecond)))
+	}
+	return strings.Join(metrics, ", ")
+}
+
+// timingToken replaces the characters that are not allowed in metric name (HTTP token).
+func timingToken(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r > 0x20 && r < 0x7f && !strings.ContainsRune("\"(),/:;<=>?@[\\]{}", r) {
+			return r
+		}
+		return '-'
+	}, name)
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func Test_Instrument(t *testing.T) {
+	sleep := func(before, after time.Duration) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(before)
+				next.ServeHTTP(w, r)
+				time.Sleep(after)
+			})
+		}
+	}
+	handlerSlow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+
+	var reported []Timing
+	handler := New(
+		Instrument(true, func(r *http.Request, list []Timing) { reported = list }),
+		Named("auth", New(Named("jwt", sleep(10*time.Millisecond, 0)), sleep(0, 0))),
+		sleep(0, 0),
+		Named("codec/lookup", sleep(0, 10*time.Millisecond)),
+	).Then(handlerSlow)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	t.Run("should send Server-Timing header", func(t *testing.T) {
+		re := regexp.MustCompile(`^auth;dur=\d+\.\d{3}, jwt;dur=\d+\.\d{3}, codec-lookup;dur=\d+\.\d{3}$`)
+		if header := w.Header().Get(serverTimingHeader); !re.MatchString(header) {
+			t.Errorf("header %q was expected to match %q", header, re)
+		}
+	})
+
+	t.Run("should report the time of named middleware excluding next handlers and nested middleware", func(t *testing.T) {
+		if len(reported) != 3 {
+			t.Fatalf("unexpected list of timings: %v", reported)
+		}
+		for i, name := range []string{"auth", "jwt", "codec/lookup"} {
+			if reported[i].Name != name {
+				t.Errorf("timing name %q was expected to be %q", reported[i].Name, name)
+			}
+		}
+		if reported[0].Duration >= 10*time.Millisecond {
+			t.Errorf("duration of %q (%v) should not include nested middleware", reported[0].Name, reported[0].Duration)
+		}
+		for _, timing := range reported[1:] {
+			if timing.Duration < 10*time.Millisecond || timing.Duration >= 50*time.Millisecond {
+				t.Errorf("duration of %q (%v) is out of expected range", timing.Name, timing.Duration)
+			}
+		}
+	})
+
+	t.Run("should measure every position of named middleware separately", func(t *testing.T) {
+		var list []Timing
+		slow := Named("slow", sleep(10*time.Millisecond, 0))
+		New(
+			Instrument(false, func(r *http.Request, timings []Timing) { list = timings }),
+			slow, sleep(0, 0), slow,
+		).Then(handlerFinal).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		if len(list) != 2 {
+			t.Fatalf("unexpected list of timings: %v", list)
+		}
+		for _, timing := range list {
+			if timing.Name != "slow" || timing.Duration < 10*time.Millisecond || timing.Duration >= 50*time.Millisecond {
+				t.Errorf("unexpected timing: %v", timing)
+			}
+		}
+	})
+
+	t.Run("should not measure the time of requests that are not instrumented", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		New(Named("jwt", middlewareOne)).Then(handlerFinal).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if header := w.Header().Get(serverTimingHeader); header != "" {
+			t.Errorf("header %q was not expected", header)
+		}
+	})
+}
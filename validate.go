@@ -0,0 +1,416 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tiny-go/errors"
+)
+
+// validateTag is a struct tag containing comma separated validation rules.
+const validateTag = "validate"
+
+// bodyKey is a private unique key that is used to put/get decoded body from the context.
+type bodyKey struct{}
+
+// Validator can be implemented by the decoded types to provide custom validation
+// logic (it is called after struct tag rules are checked).
+type Validator interface {
+	Validate() error
+}
+
+// FieldViolation describes a single validation error of the field.
+type FieldViolation struct {
+	Field   string `json:"field" xml:"field,attr"`
+	Rule    string `json:"rule,omitempty" xml:"rule,attr,omitempty"`
+	Message string `json:"message" xml:",chardata"`
+}
+
+// ValidationError contains the list of field violations, it has HTTP status code
+// 422 (Unprocessable Entity).
+type ValidationError struct {
+	XMLName    xml.Name         `json:"-" xml:"validation"`
+	Violations []FieldViolation `json:"violations" xml:"violation"`
+}
+
+// Add appends a new violation to the list.
+func (ve *ValidationError) Add(field, rule, message string) {
+	ve.Violations = append(ve.Violations, FieldViolation{Field: field, Rule: rule, Message: message})
+}
+
+// Error joins all the violations into a single message.
+func (ve *ValidationError) Error() string {
+	messages := make([]string, 0, len(ve.Violations))
+	for _, v := range ve.Violations {
+		if v.Field == "" {
+			messages = append(messages, v.Message)
+		} else {
+			messages = append(messages, v.Field+": "+v.Message)
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Code returns HTTP status code (to satisfy errors.Error interface).
+func (ve *ValidationError) Code() int { return http.StatusUnprocessableEntity }
+
+// Validate middleware decodes request body with the request codec (see Codec
+// middleware) into a value returned by factory func, validates it with ValidateStruct
+// and puts it to the request context (use BodyFromContext to retrieve the value).
+// Errors are sent with provided errors.HandlerFunc (e.g. ProblemError lists the
+// violations of validation error sent with 422 status). If it is not provided,
+// validation errors are encoded with the response codec and other errors are sent
+// with http.Error.
+// The rules of the type returned by factory (and its nested structs) are checked
+// once Validate is called, it panics if any rule is invalid or unsupported by the
+// field type (rules of the values stored in interface fields can only be checked
+// at runtime, such requests fail with 500).
+//
+// Supported struct tag rules:
+//   - required - the value should not be empty (zero)
+//   - min=N - minimal number (or length for strings, slices and maps)
+//   - max=N - maximal number (or length for strings, slices and maps)
+//   - pattern=EXPR - string should match the regular expression (it cannot contain commas)
+//
+// Example:
+//
+//	type User struct {
+//	    Name  string `json:"name" validate:"required,max=64"`
+//	    Age   int    `json:"age" validate:"min=18"`
+//	}
+//
+//	mw.New(mw.Codec(nil, codecs), mw.Validate(nil, func() interface{} { return new(User) }))
+func Validate(fn errors.HandlerFunc, factory func() interface{}) Middleware {
+	// validation errors are sent with the list of violations by default
+	sendInvalid := sendValidationError
+	if fn == nil {
+		fn = http.Error
+	} else {
+		sendInvalid = func(w http.ResponseWriter, r *http.Request, verr *ValidationError) {
+			fn(&errorWriter{ResponseWriter: w, r: r, err: verr}, verr.Error(), verr.Code())
+		}
+	}
+	if err := checkStructRules(reflect.TypeOf(factory()), map[reflect.Type]bool{}); err != nil {
+		panic(fmt.Sprintf("invalid validation rules: %s", err))
+	}
+	return func(next http.Handler) http.Handler {
+		document(next, APIDoc{Responses: []int{http.StatusUnprocessableEntity}})
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqCodec := RequestCodecFromContext(r.Context())
+			if reqCodec == nil {
+				sendError(fn, w, r, "no request codec in the context", http.StatusUnsupportedMediaType)
+				return
+			}
+			body := factory()
+			if err := reqCodec.Decoder(r.Body).Decode(body); err != nil {
+				sendError(fn, w, r, fmt.Sprintf("cannot decode request body: %s", err), http.StatusBadRequest)
+				return
+			}
+			if err := ValidateStruct(body); err != nil {
+				if verr, ok := err.(*ValidationError); ok {
+					sendInvalid(w, r, verr)
+				} else {
+					// invalid rule definition is not a client error
+					sendError(fn, w, r, err.Error(), http.StatusInternalServerError)
+				}
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), bodyKey{}, body)))
+		})
+	}
+}
+
+// BodyFromContext pulls decoded (and validated) request body from the context or
+// returns nil.
+func BodyFromContext(ctx context.Context) interface{} {
+	return ctx.Value(bodyKey{})
+}
+
+// sendValidationError encodes validation error with the response codec (JSON is
+// used by default).
+func sendValidationError(w http.ResponseWriter, r *http.Request, verr *ValidationError) {
+	if resCodec := ResponseCodecFromContext(r.Context()); resCodec != nil {
+		w.Header().Set(contentTypeHeader, resCodec.MimeType())
+		w.WriteHeader(verr.Code())
+		resCodec.Encoder(w).Encode(verr)
+		return
+	}
+	w.Header().Set(contentTypeHeader, "application/json")
+	w.WriteHeader(verr.Code())
+	json.NewEncoder(w).Encode(verr)
+}
+
+// ValidateStruct checks struct tag rules of provided value (nested structs are
+// validated recursively) and calls Validate() if value implements Validator
+// interface. It returns *ValidationError listing all the violations or an error
+// of other type if the rules of the struct are invalid.
+func ValidateStruct(v interface{}) error {
+	verr := &ValidationError{}
+	if err := validateValue(verr, "", reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	if len(verr.Violations) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// validateValue walks through struct fields and checks their rules.
+func validateValue(verr *ValidationError, path string, rv reflect.Value) error {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	fields, err := rulesOf(rv.Type())
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		name := field.name
+		if path != "" {
+			name = path + "." + name
+		}
+		checkRules(verr, name, field.rules, rv.Field(field.index))
+		if err := validateValue(verr, name, rv.Field(field.index)); err != nil {
+			return err
+		}
+	}
+	// custom validation logic
+	if rv.CanAddr() {
+		if validator, ok := rv.Addr().Interface().(Validator); ok {
+			return addValidatorError(verr, path, validator.Validate())
+		}
+	}
+	if validator, ok := rv.Interface().(Validator); ok {
+		return addValidatorError(verr, path, validator.Validate())
+	}
+	return nil
+}
+
+// addValidatorError merges the result of custom validator into the list of violations.
+func addValidatorError(verr *ValidationError, path string, err error) error {
+	switch e := err.(type) {
+	case nil:
+	case *ValidationError:
+		for _, v := range e.Violations {
+			if path != "" {
+				v.Field = strings.TrimSuffix(path+"."+v.Field, ".")
+			}
+			verr.Violations = append(verr.Violations, v)
+		}
+	default:
+		verr.Add(path, "", err.Error())
+	}
+	return nil
+}
+
+// fieldName returns the name of the field using JSON name if it was defined.
+func fieldName(field reflect.StructField) string {
+	if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+		return tag
+	}
+	return field.Name
+}
+
+// rule is a parsed validation rule of the field.
+type rule struct {
+	key   string
+	param string
+	limit float64
+	re    *regexp.Regexp
+}
+
+// fieldRules contains parsed validation rules of exported struct field.
+type fieldRules struct {
+	index int
+	name  string
+	rules []rule
+}
+
+// structRules is the result of parsing the rules of struct type.
+type structRules struct {
+	fields []fieldRules
+	err    error
+}
+
+// rulesCache contains parsed rules of the struct types (reflect.Type -> structRules).
+var rulesCache sync.Map
+
+// rulesOf returns parsed (and cached) validation rules of struct type fields.
+func rulesOf(rt reflect.Type) ([]fieldRules, error) {
+	if cached, ok := rulesCache.Load(rt); ok {
+		rules := cached.(structRules)
+		return rules.fields, rules.err
+	}
+	fields, err := parseRules(rt)
+	rulesCache.Store(rt, structRules{fields: fields, err: err})
+	return fields, err
+}
+
+// parseRules parses the rules of struct type fields. It returns an error if rule
+// definition is invalid or the rule is not supported by the type of the field.
+func parseRules(rt reflect.Type) ([]fieldRules, error) {
+	fields := make([]fieldRules, 0, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		// skip unexported fields
+		if field.PkgPath != "" {
+			continue
+		}
+		parsed := fieldRules{index: i, name: fieldName(field)}
+		if tag := field.Tag.Get(validateTag); tag != "" && tag != "-" {
+			for _, def := range strings.Split(tag, ",") {
+				r, err := parseRule(parsed.name, def, field.Type)
+				if err != nil {
+					return nil, err
+				}
+				parsed.rules = append(parsed.rules, r)
+			}
+		}
+		fields = append(fields, parsed)
+	}
+	return fields, nil
+}
+
+// parseRule parses a single rule definition of the field.
+func parseRule(name, def string, ft reflect.Type) (rule, error) {
+	r := rule{key: def}
+	if i := strings.Index(def, "="); i >= 0 {
+		r.key, r.param = def[:i], def[i+1:]
+	}
+	for ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	var err error
+	switch r.key {
+	case "required":
+	case "min", "max":
+		if r.limit, err = strconv.ParseFloat(r.param, 64); err != nil {
+			return r, fmt.Errorf("invalid %q rule of the field %q: %s", r.key, name, err)
+		}
+		switch ft.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64, reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		default:
+			return r, fmt.Errorf("%q rule is not supported by the field %q", r.key, name)
+		}
+	case "pattern":
+		if r.re, err = regexp.Compile(r.param); err != nil {
+			return r, fmt.Errorf("invalid %q rule of the field %q: %s", r.key, name, err)
+		}
+		if ft.Kind() != reflect.String {
+			return r, fmt.Errorf("%q rule is not supported by the field %q", r.key, name)
+		}
+	default:
+		return r, fmt.Errorf("unknown validation rule %q of the field %q", r.key, name)
+	}
+	return r, nil
+}
+
+// checkStructRules parses the rules of struct type and its nested struct types
+// (if type is not a struct it is ignored).
+func checkStructRules(rt reflect.Type, checked map[reflect.Type]bool) error {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt.Kind() != reflect.Struct || checked[rt] {
+		return nil
+	}
+	checked[rt] = true
+	fields, err := rulesOf(rt)
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if err := checkStructRules(rt.Field(field.index).Type, checked); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRules validates the value of the field against the list of parsed rules.
+func checkRules(verr *ValidationError, name string, rules []rule, rv reflect.Value) {
+	for _, r := range rules {
+		switch r.key {
+		case "required":
+			if isZero(rv) {
+				verr.Add(name, r.key, "is required")
+				// there is no sense to check the rest of the rules
+				return
+			}
+		case "min", "max":
+			value, unit := measure(rv)
+			if r.key == "min" && value < r.limit {
+				verr.Add(name, r.key, limitMessage("at least", r.param, unit))
+			}
+			if r.key == "max" && value > r.limit {
+				verr.Add(name, r.key, limitMessage("at most", r.param, unit))
+			}
+		case "pattern":
+			var value string
+			// nil pointers are treated as empty values
+			if elem := reflect.Indirect(rv); elem.IsValid() {
+				value = elem.String()
+			}
+			if !r.re.MatchString(value) {
+				verr.Add(name, r.key, fmt.Sprintf("should match pattern %q", r.param))
+			}
+		}
+	}
+}
+
+// limitMessage builds the message for min/max rules.
+func limitMessage(prefix, limit, unit string) string {
+	if unit != "" {
+		return fmt.Sprintf("should contain %s %s %s", prefix, limit, unit)
+	}
+	return fmt.Sprintf("should be %s %s", prefix, limit)
+}
+
+// measure returns numeric value of the field or its length (with the unit).
+func measure(rv reflect.Value) (value float64, unit string) {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			// nil pointers are treated as empty values
+			return 0, ""
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), ""
+	case reflect.String:
+		return float64(len([]rune(rv.String()))), "character(s)"
+	default:
+		return float64(rv.Len()), "element(s)"
+	}
+}
+
+// isZero checks if the value is empty.
+func isZero(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return rv.Len() == 0
+	default:
+		return rv.IsZero()
+	}
+}
@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tiny-go/codec/driver"
+	"github.com/tiny-go/codec/driver/json"
+	"github.com/tiny-go/codec/driver/xml"
+)
+
+type validatedAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type validatedUser struct {
+	Name    string           `json:"name" validate:"required,max=5"`
+	Age     int              `json:"age" validate:"min=18,max=99"`
+	Email   string           `json:"email" validate:"pattern=^[^@]+@[^@]+$"`
+	Tags    []string         `json:"tags" validate:"max=2"`
+	Address validatedAddress `json:"address"`
+}
+
+func (u *validatedUser) Validate() error {
+	if u.Name == "admin" {
+		return errors.New("reserved name")
+	}
+	return nil
+}
+
+func Test_ValidateStruct(t *testing.T) {
+	type testCase struct {
+		title string
+		value interface{}
+		err   string
+	}
+
+	cases := []testCase{
+		{
+			title: "valid struct should not return any errors",
+			value: &validatedUser{Name: "john", Age: 30, Email: "john@example.com", Address: validatedAddress{City: "Kyiv"}},
+		},
+		{
+			title: "should list all the violated rules including nested structs",
+			value: &validatedUser{Name: "johnny", Age: 10, Email: "john", Tags: []string{"a", "b", "c"}},
+			err: "name: should contain at most 5 character(s); age: should be at least 18; email: should match pattern \"^[^@]+@[^@]+$\"; " +
+				"tags: should contain at most 2 element(s); address.city: is required",
+		},
+		{
+			title: "should call custom validator",
+			value: &validatedUser{Name: "admin", Age: 30, Email: "a@b", Address: validatedAddress{City: "Kyiv"}},
+			err:   "reserved name",
+		},
+		{
+			title: "should fail if rule is not supported by the field type",
+			value: &struct {
+				Flag bool `validate:"min=1"`
+			}{},
+			err: "\"min\" rule is not supported by the field \"Flag\"",
+		},
+		{
+			title: "should fail if rule is unknown",
+			value: &struct {
+				Value string `validate:"unknown"`
+			}{},
+			err: "unknown validation rule \"unknown\" of the field \"Value\"",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			err := ValidateStruct(tc.value)
+			switch {
+			case err == nil && tc.err != "":
+				t.Errorf("error %q was expected", tc.err)
+			case err != nil && err.Error() != tc.err:
+				t.Errorf("error %q was expected to be %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	type testCase struct {
+		title  string
+		ctype  string
+		accept string
+		body   string
+		code   int
+		out    string
+	}
+
+	cases := []testCase{
+		{
+			title:  "should pass decoded body to the next handler",
+			ctype:  "application/json",
+			accept: "application/json",
+			body:   `{"name":"john","age":30,"email":"john@example.com","address":{"city":"Kyiv"}}`,
+			code:   http.StatusOK,
+			out:    "john\n",
+		},
+		{
+			title:  "should fail if request body cannot be decoded",
+			ctype:  "application/json",
+			accept: "application/json",
+			body:   `{"name":`,
+			code:   http.StatusBadRequest,
+			out:    "cannot decode request body: unexpected EOF\n",
+		},
+		{
+			title:  "should send violations encoded with the response codec",
+			ctype:  "application/json",
+			accept: "application/json",
+			body:   `{"name":"john","age":30,"email":"john@example.com"}`,
+			code:   http.StatusUnprocessableEntity,
+			out:    "{\"violations\":[{\"field\":\"address.city\",\"rule\":\"required\",\"message\":\"is required\"}]}\n",
+		},
+		{
+			title:  "should send violations encoded with non-default response codec",
+			ctype:  "application/json",
+			accept: "application/xml",
+			body:   `{"name":"john","age":30,"email":"john@example.com"}`,
+			code:   http.StatusUnprocessableEntity,
+			out:    "<validation><violation field=\"address.city\" rule=\"required\">is required</violation></validation>",
+		},
+	}
+
+	handler := New(
+		Codec(nil, driver.DummyRegistry{&json.JSON{}, &xml.XML{}}),
+		Validate(nil, func() interface{} { return new(validatedUser) }),
+	).Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(BodyFromContext(r.Context()).(*validatedUser).Name + "\n"))
+	}))
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodPost, "", strings.NewReader(tc.body))
+			r.Header.Set(contentTypeHeader, tc.ctype)
+			r.Header.Set(acceptHeader, tc.accept)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tc.code {
+				t.Errorf("status code %d was expected to be %d", w.Code, tc.code)
+			}
+			if w.Body.String() != tc.out {
+				t.Errorf("response body %q was expected to be %q", w.Body.String(), tc.out)
+			}
+		})
+	}
+
+	t.Run("should fail without request codec in the context", func(t *testing.T) {
+		r, _ := http.NewRequest(http.MethodPost, "", strings.NewReader("{}"))
+		w := httptest.NewRecorder()
+		Validate(nil, func() interface{} { return new(validatedUser) })(nil).ServeHTTP(w, r)
+		if w.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("status code %d was expected to be %d", w.Code, http.StatusUnsupportedMediaType)
+		}
+	})
+
+	t.Run("should panic if validation rules are invalid", func(t *testing.T) {
+		type invalid struct {
+			Nested *struct {
+				Value string `validate:"pattern=("`
+			}
+		}
+		defer func() {
+			expected := "invalid validation rules: invalid \"pattern\" rule of the field \"Value\": " +
+				"error parsing regexp: missing closing ): `(`"
+			if r := recover(); r != expected {
+				t.Errorf("panic %v was expected to be %q", r, expected)
+			}
+		}()
+		Validate(nil, func() interface{} { return new(invalid) })
+	})
+
+	t.Run("should respond with 500 if runtime value has invalid rules", func(t *testing.T) {
+		type dynamic struct {
+			Value interface{}
+		}
+		handler := New(
+			Codec(nil, driver.DummyRegistry{&json.JSON{}}),
+			Validate(nil, func() interface{} {
+				return &dynamic{Value: &struct {
+					Flag bool `validate:"max=1"`
+				}{}}
+			}),
+		).Then(http.HandlerFunc(handlerFinal))
+		r, _ := http.NewRequest(http.MethodPost, "", strings.NewReader("{}"))
+		r.Header.Set(contentTypeHeader, "application/json")
+		r.Header.Set(acceptHeader, "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("status code %d was expected to be %d", w.Code, http.StatusInternalServerError)
+		}
+	})
+}
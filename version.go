@@ -0,0 +1,232 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tiny-go/errors"
+)
+
+const (
+	deprecationHeader = "Deprecation"
+	sunsetHeader      = "Sunset"
+	linkHeader        = "Link"
+)
+
+var (
+	// pathVersionRegexp matches version prefix of URL path (e.g. "/v2/users").
+	pathVersionRegexp = regexp.MustCompile(`^/[vV](\d[^/]*)(/.*)?$`)
+	// vendorVersionRegexp matches versioned vendor media types (e.g. "application/vnd.acme.v2+json").
+	vendorVersionRegexp = regexp.MustCompile(`^([^/]+)/vnd\.(?:[^+]*\.)?v(\d[^+.]*)\+(.+)$`)
+)
+
+// versionKey is a private unique key that is used to put/get API version from the context.
+type versionKey struct{}
+
+// VersionConfig describes where Version middleware looks for the API version.
+type VersionConfig struct {
+	// Supported is the list of accepted versions (any version is accepted if empty).
+	Supported []string
+	// Default is the version of the requests that do not specify it.
+	Default string
+	// Header is the name of request header containing the version (e.g. "Api-Version"),
+	// the header is ignored if empty.
+	Header string
+	// PathPrefix enables URL path prefix (e.g. "/v2/users"), the prefix is removed
+	// from the path of the request passed to the next handler.
+	PathPrefix bool
+}
+
+// Version middleware resolves the API version of the request and puts it into the
+// context (see VersionFromContext). The version is taken from (in order of priority):
+//   - URL path prefix (if enabled)
+//   - request header (if configured)
+//   - "Accept"/"Content-Type" media types, either vendor ("application/vnd.acme.v2+json")
+//     or "version" parameter ("application/json; version=2")
+//
+// Versioned media types are replaced with the plain ones ("application/json"),
+// so Codec middleware should follow Version in the chain. The versions are compared
+// without "v" prefix ("v2" and "2" are the same version). The requests with missing
+// (and no default) or unsupported version are rejected with 400 (Bad Request).
+func Version(fn errors.HandlerFunc, config VersionConfig) Middleware {
+	if fn == nil {
+		fn = http.Error
+	}
+	supported := make(map[string]bool, len(config.Supported))
+	for _, version := range config.Supported {
+		supported[normalizeVersion(version)] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var version string
+			path := r.URL.Path
+			if config.PathPrefix {
+				if match := pathVersionRegexp.FindStringSubmatch(path); match != nil {
+					version, path = match[1], match[2]
+					if path == "" {
+						path = "/"
+					}
+				}
+			}
+			if version == "" && config.Header != "" {
+				version = r.Header.Get(config.Header)
+			}
+			header := r.Header.Clone()
+			for _, name := range []string{acceptHeader, contentTypeHeader} {
+				value, mediaVersion := unversionMediaTypes(header.Get(name))
+				if mediaVersion == "" {
+					continue
+				}
+				header.Set(name, value)
+				if version == "" {
+					version = mediaVersion
+				}
+			}
+			if version = normalizeVersion(version); version == "" {
+				version = normalizeVersion(config.Default)
+			}
+			switch {
+			case version == "" && len(supported) > 0:
+				sendError(fn, w, r, "API version is required", http.StatusBadRequest)
+				return
+			case version != "" && len(supported) > 0 && !supported[version]:
+				sendError(fn, w, r, fmt.Sprintf("unsupported API version %q", version), http.StatusBadRequest)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), versionKey{}, version))
+			r.Header = header
+			if path != r.URL.Path {
+				u := *r.URL
+				u.Path, u.RawPath = path, ""
+				r.URL = &u
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// VersionFromContext returns the API version resolved by Version middleware
+// (without "v" prefix) or an empty string.
+func VersionFromContext(ctx context.Context) string {
+	version, _ := ctx.Value(versionKey{}).(string)
+	return version
+}
+
+// VersionIs returns a predicate matching the requests of provided API versions,
+// it allows to apply the middleware per version (see Middleware.When).
+//
+// Example:
+//
+//	controller.AddMiddleware(http.MethodGet,
+//	    mw.Deprecate(mw.Deprecation{Sunset: sunset}).When(mw.VersionIs("v1")),
+//	)
+func VersionIs(versions ...string) Predicate {
+	set := make(map[string]bool, len(versions))
+	for _, version := range versions {
+		set[normalizeVersion(version)] = true
+	}
+	return func(r *http.Request) bool {
+		return r != nil && set[VersionFromContext(r.Context())]
+	}
+}
+
+// VersionHandlers dispatches the requests to the handlers by API version (see
+// Version), it responds with 404 (Not Found) if there is no handler for the version.
+//
+// Example:
+//
+//	router.Mount("/users", controller, map[string]http.Handler{
+//	    http.MethodGet: mw.VersionHandlers{"v1": listV1, "v2": listV2},
+//	})
+type VersionHandlers map[string]http.Handler
+
+// ServeHTTP calls the handler of the request version.
+func (vh VersionHandlers) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	version := VersionFromContext(r.Context())
+	for key, handler := range vh {
+		if normalizeVersion(key) == version {
+			handler.ServeHTTP(w, r)
+			return
+		}
+	}
+	http.Error(w, fmt.Sprintf("unsupported API version %q", version), http.StatusNotFound)
+}
+
+// Deprecation describes deprecated API version.
+type Deprecation struct {
+	// Date is the date of deprecation (the version is just marked as deprecated if zero).
+	Date time.Time
+	// Sunset is the date when the version becomes unavailable (optional).
+	Sunset time.Time
+	// Link is the URL of the documentation about deprecation (optional).
+	Link string
+}
+
+// Deprecate middleware adds "Deprecation", "Sunset" and "Link" headers to the
+// response, use it with VersionIs to mark old API versions as deprecated.
+func Deprecate(deprecation Deprecation) Middleware {
+	value := "true"
+	if !deprecation.Date.IsZero() {
+		value = fmt.Sprintf("@%d", deprecation.Date.Unix())
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(deprecationHeader, value)
+			if !deprecation.Sunset.IsZero() {
+				w.Header().Set(sunsetHeader, deprecation.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if deprecation.Link != "" {
+				w.Header().Add(linkHeader, fmt.Sprintf("<%s>; rel=\"deprecation\"", deprecation.Link))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// normalizeVersion removes "v" prefix of the version.
+func normalizeVersion(version string) string {
+	return strings.TrimLeft(strings.TrimSpace(version), "vV")
+}
+
+// unversionMediaTypes replaces versioned media types of the header value with
+// the plain ones and returns the version of the first versioned media type.
+func unversionMediaTypes(value string) (string, string) {
+	if value == "" {
+		return value, ""
+	}
+	var version string
+	types := strings.Split(value, ",")
+	for i, elem := range types {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(elem))
+		if err != nil {
+			continue
+		}
+		var found string
+		if match := vendorVersionRegexp.FindStringSubmatch(mediaType); match != nil {
+			found, mediaType = match[2], match[1]+"/"+match[3]
+		}
+		if v, ok := params["version"]; ok {
+			found = v
+			delete(params, "version")
+		}
+		if found == "" {
+			continue
+		}
+		if version == "" {
+			version = found
+		}
+		types[i] = mime.FormatMediaType(mediaType, params)
+	}
+	if version == "" {
+		return value, ""
+	}
+	for i := range types {
+		types[i] = strings.TrimSpace(types[i])
+	}
+	return strings.Join(types, ", "), version
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tiny-go/codec/driver"
+	codecJSON "github.com/tiny-go/codec/driver/json"
+)
+
+func Test_Version(t *testing.T) {
+	type testCase struct {
+		title   string
+		path    string
+		headers map[string]string
+		code    int
+		body    string
+	}
+
+	handler := New(
+		Version(nil, VersionConfig{Supported: []string{"v1", "v2"}, Default: "v1", Header: "Api-Version", PathPrefix: true}),
+		Codec(nil, driver.DummyRegistry{&codecJSON.JSON{}}),
+	).Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %s", VersionFromContext(r.Context()), r.URL.Path, ResponseCodecFromContext(r.Context()).MimeType())
+	}))
+
+	cases := []testCase{
+		{
+			title:   "should use default version",
+			path:    "/users",
+			headers: map[string]string{acceptHeader: "application/json", contentTypeHeader: "application/json"},
+			code:    http.StatusOK,
+			body:    "1 /users application/json",
+		},
+		{
+			title:   "should resolve the version from URL path prefix",
+			path:    "/v2/users",
+			headers: map[string]string{acceptHeader: "application/vnd.acme.v1+json", contentTypeHeader: "application/json", "Api-Version": "1"},
+			code:    http.StatusOK,
+			body:    "2 /users application/json",
+		},
+		{
+			title:   "should resolve the version from the header",
+			path:    "/users",
+			headers: map[string]string{acceptHeader: "application/json", contentTypeHeader: "application/json", "Api-Version": "v2"},
+			code:    http.StatusOK,
+			body:    "2 /users application/json",
+		},
+		{
+			title:   "should resolve the version from vendor media type",
+			path:    "/users",
+			headers: map[string]string{acceptHeader: "application/vnd.acme.v2+json", contentTypeHeader: "application/vnd.acme.v2+json"},
+			code:    http.StatusOK,
+			body:    "2 /users application/json",
+		},
+		{
+			title:   "should resolve the version from media type parameter",
+			path:    "/users",
+			headers: map[string]string{acceptHeader: "application/json; version=2", contentTypeHeader: "application/json"},
+			code:    http.StatusOK,
+			body:    "2 /users application/json",
+		},
+		{
+			title:   "should reject unsupported version",
+			path:    "/v3",
+			headers: map[string]string{acceptHeader: "application/json"},
+			code:    http.StatusBadRequest,
+			body:    "unsupported API version \"3\"\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			for key, value := range tc.headers {
+				r.Header.Set(key, value)
+			}
+			handler.ServeHTTP(w, r)
+			if w.Code != tc.code {
+				t.Errorf("status code %d was expected to be %d", w.Code, tc.code)
+			}
+			if w.Body.String() != tc.body {
+				t.Errorf("the body %q was expected to be %q", w.Body.String(), tc.body)
+			}
+		})
+	}
+
+	t.Run("should require the version if there is no default one", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		Version(nil, VersionConfig{Supported: []string{"1"}})(handlerFinal).
+			ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusBadRequest || w.Body.String() != "API version is required\n" {
+			t.Errorf("unexpected response: %d %q", w.Code, w.Body.String())
+		}
+	})
+}
+
+func Test_VersionHandlers(t *testing.T) {
+	type testCase struct {
+		title       string
+		version     string
+		code        int
+		body        string
+		deprecation string
+		sunset      string
+		link        string
+	}
+
+	sunset := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	controller := NewBaseController()
+	controller.AddMiddleware(AnyMethod, Version(nil, VersionConfig{Header: "Api-Version"}))
+	controller.AddMiddleware(http.MethodGet, Deprecate(Deprecation{
+		Date:   time.Unix(1700000000, 0),
+		Sunset: sunset,
+		Link:   "https://example.com/migrate",
+	}).When(VersionIs("v1")))
+	handler := ServeController(controller, map[string]http.Handler{
+		http.MethodGet: VersionHandlers{"v1": http.HandlerFunc(handlerOne), "v2": http.HandlerFunc(handlerTwo)},
+	})
+
+	cases := []testCase{
+		{
+			title:       "should call the handler of deprecated version",
+			version:     "1",
+			code:        http.StatusOK,
+			body:        "/first handler",
+			deprecation: "@1700000000",
+			sunset:      "Tue, 01 Jan 2030 00:00:00 GMT",
+			link:        "<https://example.com/migrate>; rel=\"deprecation\"",
+		},
+		{
+			title:   "should call the handler of current version",
+			version: "v2",
+			code:    http.StatusOK,
+			body:    "/second handler",
+		},
+		{
+			title:   "should respond with 404 if there is no handler for the version",
+			version: "3",
+			code:    http.StatusNotFound,
+			body:    "unsupported API version \"3\"\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Api-Version", tc.version)
+			handler.ServeHTTP(w, r)
+			if w.Code != tc.code {
+				t.Errorf("status code %d was expected to be %d", w.Code, tc.code)
+			}
+			if w.Body.String() != tc.body {
+				t.Errorf("the body %q was expected to be %q", w.Body.String(), tc.body)
+			}
+			for header, expected := range map[string]string{
+				deprecationHeader: tc.deprecation, sunsetHeader: tc.sunset, linkHeader: tc.link,
+			} {
+				if value := w.Header().Get(header); value != expected {
+					t.Errorf("header %q value %q was expected to be %q", header, value, expected)
+				}
+			}
+		})
+	}
+}